@@ -31,6 +31,32 @@ func init() {
 	_ = viper.BindEnv("load.code-file", "LOAD_CODE_FILE")
 	_ = viper.BindEnv("load.clients-file", "LOAD_CLIENTS_FILE")
 	_ = viper.BindEnv("load.tokens-file", "LOAD_TOKENS_FILE")
+	_ = viper.BindEnv("load.users-file", "LOAD_USERS_FILE")
+	_ = viper.BindEnv("load.apps-file", "LOAD_APPS_FILE")
+	_ = viper.BindEnv("load.orgs-file", "LOAD_ORGS_FILE")
+	_ = viper.BindEnv("load.repos-file", "LOAD_REPOS_FILE")
+	_ = viper.BindEnv("load.oidc-key-file", "LOAD_OIDC_KEY_FILE")
+
+	_ = viper.BindEnv("users.default-login", "USERS_DEFAULT_LOGIN")
+
+	_ = viper.BindEnv("api.mode", "API_MODE")
+	_ = viper.BindEnv("api.bind", "API_BIND")
+
+	_ = viper.BindEnv("oidc.issuer", "OIDC_ISSUER")
+	_ = viper.BindEnv("oidc.token-expire", "OIDC_TOKEN_EXPIRE")
+	_ = viper.BindEnv("oidc.audience", "OIDC_AUDIENCE")
+	_ = viper.BindEnv("oidc.repository", "OIDC_REPOSITORY")
+	_ = viper.BindEnv("oidc.ref", "OIDC_REF")
+	_ = viper.BindEnv("oidc.workflow", "OIDC_WORKFLOW")
+
+	_ = viper.BindEnv("oauth.require-state", "OAUTH_REQUIRE_STATE")
+	_ = viper.BindEnv("oauth.code-expire", "OAUTH_CODE_EXPIRE")
+	_ = viper.BindEnv("oauth.interactive", "OAUTH_INTERACTIVE")
+	_ = viper.BindEnv("oauth.token-expire", "OAUTH_TOKEN_EXPIRE")
+	_ = viper.BindEnv("oauth.user-token-expire", "OAUTH_USER_TOKEN_EXPIRE")
+	_ = viper.BindEnv("oauth.refresh-token-expire", "OAUTH_REFRESH_TOKEN_EXPIRE")
+	_ = viper.BindEnv("oauth.device-code-expire", "OAUTH_DEVICE_CODE_EXPIRE")
+	_ = viper.BindEnv("oauth.device-interval", "OAUTH_DEVICE_INTERVAL")
 }
 
 func main() {
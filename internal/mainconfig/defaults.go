@@ -20,6 +20,18 @@ func ConfigInit() {
 	viper.AddConfigPath(".")
 
 	viper.SetDefault("server.bind", "localhost:8080")
+	viper.SetDefault("api.mode", "ghes")
+	viper.SetDefault("api.bind", "localhost:8081")
+	viper.SetDefault("oauth.require-state", false)
+	viper.SetDefault("oauth.code-expire", "10m")
+	viper.SetDefault("oauth.interactive", false)
+	viper.SetDefault("oauth.token-expire", "0s")
+	viper.SetDefault("oauth.user-token-expire", "8h")
+	viper.SetDefault("oauth.refresh-token-expire", "4416h")
+	viper.SetDefault("oauth.device-code-expire", "15m")
+	viper.SetDefault("oauth.device-interval", "5s")
+	viper.SetDefault("users.default-login", "octocat")
+	viper.SetDefault("oidc.token-expire", "5m")
 	// viper.SetDefault("general.jitter", "10s")
 	// viper.SetDefault("general.retry", true)
 	// viper.SetDefault("general.max-retries", 3)
@@ -4,5 +4,5 @@ import "embed"
 
 // Content is the static source data.
 //
-//go:embed *.json
+//go:embed *.json *.html
 var Content embed.FS
@@ -0,0 +1,66 @@
+package mockghauth
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// ErrUnknownAPIMode is returned when api.mode is not a known API layout.
+var ErrUnknownAPIMode = errors.New("unknown api mode")
+
+// APIMode selects where the REST API is served.
+type APIMode string
+
+const (
+	// APIModeGHES serves the API under /api/v3 on the main listener, as GitHub
+	// Enterprise Server does.
+	APIModeGHES APIMode = "ghes"
+	// APIModeDotcom serves the API at the root of the main listener, the paths
+	// api.github.com uses.
+	APIModeDotcom APIMode = "dotcom"
+	// APIModeHost serves the API at the root of a separate listener, as
+	// api.github.com is a separate host to github.com.
+	APIModeHost APIMode = "host"
+
+	ghesAPIPrefix = "/api/v3"
+)
+
+// apiLayout returns the base URL API resource URLs are generated from and the
+// path prefix API routes are registered under. In host mode the API is served
+// from apiBind instead of baseURL.
+func apiLayout(mode APIMode, baseURL *url.URL, apiBind string) (*url.URL, string, error) {
+	switch mode {
+	case "", APIModeGHES:
+		return urlMustResolve(baseURL, ghesAPIPrefix+"/"), ghesAPIPrefix, nil
+	case APIModeDotcom:
+		return urlMustResolve(baseURL, "/"), "/", nil
+	case APIModeHost:
+		apiURL, err := url.Parse("http://" + apiBind + "/")
+		if err != nil {
+			return nil, "", fmt.Errorf("unable to parse api.bind(%s): %w", apiBind, err)
+		}
+
+		return apiURL, "/", nil
+	}
+
+	return nil, "", fmt.Errorf("%w: %s", ErrUnknownAPIMode, mode)
+}
+
+// listenAddress returns the address a listener bound to bind accepts
+// connections on, every interface on its port.
+func listenAddress(bind string) string {
+	if _, port, err := net.SplitHostPort(bind); err == nil {
+		return ":" + port
+	}
+
+	return bind
+}
+
+// apiMustResolve returns the URL of the API resource at path, which is
+// relative to the API root whatever the API mode.
+func apiMustResolve(apiURL *url.URL, path string) *url.URL {
+	return urlMustResolve(apiURL, strings.TrimPrefix(path, "/"))
+}
@@ -0,0 +1,171 @@
+package mockghauth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const clientContextKey = "mockghauth.client"
+
+// applicationTokenRequest is the body of the OAuth application token API.
+type applicationTokenRequest struct {
+	AccessToken string `json:"access_token"`
+}
+
+// requireClientAuth authenticates the request with the HTTP Basic client
+// credentials of the client in the path, storing the client in the context.
+func (s *Server) requireClientAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, secret, ok := c.Request.BasicAuth()
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, UnauthorizedGitHubAPIError())
+			return
+		}
+
+		client, clientExists := s.clients.Get(id)
+		if !clientExists || !client.VerifySecret(secret) || client.ID != c.Param("client_id") {
+			c.AbortWithStatusJSON(http.StatusNotFound, NotFoundGitHubAPIError())
+			return
+		}
+
+		c.Set(clientContextKey, client)
+		c.Next()
+	}
+}
+
+// contextClient returns the client stored by requireClientAuth.
+func contextClient(c *gin.Context) *Client {
+	if v, ok := c.Get(clientContextKey); ok {
+		if client, isClient := v.(*Client); isClient {
+			return client
+		}
+	}
+
+	return nil
+}
+
+// bindApplicationToken reads the access_token from the request body and
+// returns it with the token it names, when that token was issued to the
+// authenticated client. When it was not the error response has already been
+// written and false is returned.
+func (s *Server) bindApplicationToken(c *gin.Context) (string, *Token, bool) {
+	var req applicationTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.AccessToken == "" {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, ValidationGitHubAPIError("access_token"))
+		return "", nil, false
+	}
+
+	tok, ok := s.tokens.Get(req.AccessToken)
+	if !ok || tok.Expired(time.Now()) || tok.ClientID != contextClient(c).ID {
+		c.AbortWithStatusJSON(http.StatusNotFound, NotFoundGitHubAPIError())
+		return "", nil, false
+	}
+
+	return req.AccessToken, tok, true
+}
+
+// applicationsCheckToken checks a token is valid for the client.
+func (s *Server) applicationsCheckToken(c *gin.Context) {
+	token, tok, ok := s.bindApplicationToken(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, s.authorization(contextClient(c), token, tok))
+}
+
+// applicationsResetToken replaces a token with a new one for the same
+// authorization, the old token stops working.
+func (s *Server) applicationsResetToken(c *gin.Context) {
+	token, _, ok := s.bindApplicationToken(c)
+	if !ok {
+		return
+	}
+
+	newToken, reset := s.tokens.Reset(token)
+	if !reset {
+		c.AbortWithStatusJSON(http.StatusNotFound, NotFoundGitHubAPIError())
+		return
+	}
+
+	tok, _ := s.tokens.Get(newToken)
+
+	c.JSON(http.StatusOK, s.authorization(contextClient(c), newToken, tok))
+}
+
+// applicationsDeleteToken revokes a single token.
+func (s *Server) applicationsDeleteToken(c *gin.Context) {
+	token, _, ok := s.bindApplicationToken(c)
+	if !ok {
+		return
+	}
+
+	s.tokens.Delete(token)
+
+	c.Status(http.StatusNoContent)
+}
+
+// applicationsDeleteGrant revokes every token, and refresh token, the client
+// holds for the user the given token was issued to.
+func (s *Server) applicationsDeleteGrant(c *gin.Context) {
+	_, tok, ok := s.bindApplicationToken(c)
+	if !ok {
+		return
+	}
+
+	client := contextClient(c)
+	s.tokens.DeleteGrant(client.ID, tok.User)
+	s.refresh.DeleteGrant(client.ID, tok.User)
+
+	c.Status(http.StatusNoContent)
+}
+
+// authorization returns the authorization object GitHub describes token with.
+func (s *Server) authorization(client *Client, token string, tok *Token) *GitHubAuthorization {
+	hashed := sha256.Sum256([]byte(token))
+
+	appName := client.Name
+	if appName == "" {
+		appName = client.ID
+	}
+
+	appURL := s.baseURL.String()
+	if callbacks := client.Callbacks(); len(callbacks) > 0 {
+		appURL = callbacks[0]
+	}
+
+	out := &GitHubAuthorization{
+		ID:             tok.ID,
+		URL:            apiMustResolve(s.apiURL, "/authorizations/"+strconv.FormatInt(tok.ID, 10)).String(),
+		Scopes:         tok.Scopes,
+		Token:          token,
+		TokenLastEight: token[max(0, len(token)-8):],
+		HashedToken:    hex.EncodeToString(hashed[:]),
+		App: GitHubAuthorizationApp{
+			ClientID: client.ID,
+			Name:     appName,
+			URL:      appURL,
+		},
+		UpdatedAt: tok.CreatedAt,
+		CreatedAt: tok.CreatedAt,
+	}
+
+	if out.Scopes == nil {
+		out.Scopes = Scopes{}
+	}
+
+	if !tok.ExpiresAt.IsZero() {
+		out.ExpiresAt = &tok.ExpiresAt
+	}
+
+	if user, ok := s.users.Get(tok.User); ok {
+		out.User = user.WithURLs(s.baseURL, s.apiURL).ForScopes(nil)
+	}
+
+	return out
+}
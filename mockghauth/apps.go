@@ -0,0 +1,210 @@
+package mockghauth
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Installation is a fixture installation of a GitHub App on an account.
+type Installation struct {
+	ID                  int64             `json:"id"`
+	Account             string            `json:"account"`
+	TargetType          string            `json:"target_type,omitempty"`
+	RepositorySelection string            `json:"repository_selection,omitempty"`
+	Repositories        []string          `json:"repositories,omitempty"`
+	Permissions         map[string]string `json:"permissions,omitempty"`
+	Events              []string          `json:"events,omitempty"`
+	Suspended           bool              `json:"suspended,omitempty"`
+}
+
+// HasRepository reports whether the installation can access repo, every
+// repository when its selection is all.
+func (i *Installation) HasRepository(repo string) bool {
+	if i.RepositorySelection != repositorySelectionSelected {
+		return true
+	}
+
+	return slices.ContainsFunc(i.Repositories, func(v string) bool {
+		return strings.EqualFold(v, repo)
+	})
+}
+
+// App is a fixture GitHub App. PublicKey holds the PEM encoded key JWTs are
+// verified with, either the public key or the private key it is taken from.
+type App struct {
+	ID            int64             `json:"id"`
+	Slug          string            `json:"slug"`
+	Name          string            `json:"name,omitempty"`
+	Description   string            `json:"description,omitempty"`
+	ClientID      string            `json:"client_id,omitempty"`
+	Owner         string            `json:"owner,omitempty"`
+	PublicKey     string            `json:"public_key"`
+	Permissions   map[string]string `json:"permissions,omitempty"`
+	Events        []string          `json:"events,omitempty"`
+	Installations []*Installation   `json:"installations,omitempty"`
+
+	key *rsa.PublicKey
+}
+
+// Installation returns the app's installation with id.
+func (a *App) Installation(id int64) (*Installation, bool) {
+	for _, v := range a.Installations {
+		if v.ID == id {
+			return v, true
+		}
+	}
+
+	return nil, false
+}
+
+type Apps struct {
+	lock sync.RWMutex
+	apps map[int64]*App
+}
+
+func NewApps() *Apps {
+	return &Apps{
+		apps: make(map[int64]*App),
+	}
+}
+
+// Installation returns the installation with id and the app it belongs to.
+func (a *Apps) Installation(id int64) (*App, *Installation, bool) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	for _, app := range a.apps {
+		if installation, ok := app.Installation(id); ok {
+			return app, installation, true
+		}
+	}
+
+	return nil, nil, false
+}
+
+// ReadFile loads apps from a JSON object keyed by app ID. Entries without an
+// id take it from their key.
+func (a *Apps) ReadFile(filename string) error {
+	if filename != "" {
+		buf, fileErr := os.ReadFile(filename)
+		if fileErr != nil {
+			return fmt.Errorf("unable to read apps-file(%s): %w", filename, fileErr)
+		}
+
+		apps := map[string]*App{}
+		if err := json.NewDecoder(bytes.NewReader(buf)).Decode(&apps); err != nil {
+			return fmt.Errorf("unable to parse apps-file(%s): %w", filename, err)
+		}
+
+		for k, app := range apps {
+			if app.ID == 0 {
+				id, err := strconv.ParseInt(k, 10, 64)
+				if err != nil {
+					return fmt.Errorf("unable to parse apps-file(%s): app %q: %w", filename, k, err)
+				}
+
+				app.ID = id
+			}
+
+			if err := a.Add(app); err != nil {
+				return fmt.Errorf("unable to parse apps-file(%s): app %q: %w", filename, k, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (a *Apps) WriteFile(filename string) error {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	apps := make(map[string]*App, len(a.apps))
+	for k, v := range a.apps {
+		apps[strconv.FormatInt(k, 10)] = v
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := json.NewEncoder(buf).Encode(apps); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, buf.Bytes(), 0o600)
+}
+
+// Add adds or replaces an app, parsing its key.
+func (a *Apps) Add(app *App) error {
+	key, err := ParseRSAPublicKeyPEM([]byte(app.PublicKey))
+	if err != nil {
+		return err
+	}
+
+	app.key = key
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.apps[app.ID] = app
+
+	return nil
+}
+
+func (a *Apps) Get(id int64) (*App, bool) {
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	v, ok := a.apps[id]
+
+	return v, ok
+}
+
+// Find returns the app a JWT issuer names, either its numeric ID or its
+// client ID.
+func (a *Apps) Find(issuer string) (*App, bool) {
+	if id, err := strconv.ParseInt(issuer, 10, 64); err == nil {
+		return a.Get(id)
+	}
+
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+
+	for _, v := range a.apps {
+		if v.ClientID != "" && v.ClientID == issuer {
+			return v, true
+		}
+	}
+
+	return nil, false
+}
+
+// VerifyJWT returns the app that signed token, checking the claims GitHub
+// checks: iss names a known app, iat is not in the future and exp is in the
+// future but no more than ten minutes away.
+func (a *Apps) VerifyJWT(token string, now time.Time) (*App, error) {
+	claims, signingInput, sig, err := parseAppJWT(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = checkAppJWTTimes(claims, now); err != nil {
+		return nil, err
+	}
+
+	app, ok := a.Find(claims.issuer())
+	if !ok {
+		return nil, ErrJWTIssuer
+	}
+
+	if err = verifyAppJWTSignature(app.key, signingInput, sig); err != nil {
+		return nil, err
+	}
+
+	return app, nil
+}
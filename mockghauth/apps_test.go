@@ -0,0 +1,103 @@
+package mockghauth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dosquad/mock-oauth-test-server/mockghauth"
+)
+
+func loadAppKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	buf, err := os.ReadFile("../testdata/app-private-key.pem")
+	if err != nil {
+		t.Fatalf("os.ReadFile() error = %v", err)
+	}
+
+	key, err := mockghauth.ParseRSAPrivateKeyPEM(buf)
+	if err != nil {
+		t.Fatalf("ParseRSAPrivateKeyPEM() error = %v", err)
+	}
+
+	return key
+}
+
+func TestApps_ReadFile(t *testing.T) {
+	apps := mockghauth.NewApps()
+	if err := apps.ReadFile("../testdata/apps.json"); err != nil {
+		t.Fatalf("Apps.ReadFile() error = %v", err)
+	}
+
+	app, ok := apps.Get(1)
+	if !ok {
+		t.Fatalf("Apps.Get(1) expected to find the app")
+	}
+
+	if app.Slug != "mock-app" || len(app.Installations) != 3 {
+		t.Errorf("Apps.Get(1) = %s with %d installations, expected mock-app with 3", app.Slug, len(app.Installations))
+	}
+
+	if byClientID, found := apps.Find("Iv1.mockapp"); !found || byClientID.ID != 1 {
+		t.Errorf("Apps.Find(client id) expected to find app 1")
+	}
+
+	if err := apps.ReadFile("../testdata/missing.json"); err == nil {
+		t.Errorf("Apps.ReadFile() missing file expected an error")
+	}
+}
+
+func TestApps_VerifyJWT(t *testing.T) {
+	apps := mockghauth.NewApps()
+	if err := apps.ReadFile("../testdata/apps.json"); err != nil {
+		t.Fatalf("Apps.ReadFile() error = %v", err)
+	}
+
+	key := loadAppKey(t)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey() error = %v", err)
+	}
+
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(9*time.Minute)
+
+	tests := []struct {
+		name    string
+		key     *rsa.PrivateKey
+		issuer  string
+		iat     time.Time
+		exp     time.Time
+		wantErr error
+	}{
+		{"valid app id issuer", key, "1", past, future, nil},
+		{"valid client id issuer", key, "Iv1.mockapp", past, future, nil},
+		{"unknown app", key, "2", past, future, mockghauth.ErrJWTIssuer},
+		{"wrong key", otherKey, "1", past, future, mockghauth.ErrJWTSignature},
+		{"expired", key, "1", now.Add(-20 * time.Minute), now.Add(-10 * time.Minute), mockghauth.ErrJWTExpired},
+		{"expiry too far", key, "1", past, now.Add(30 * time.Minute), mockghauth.ErrJWTExpiryTooFar},
+		{"issued in future", key, "1", now.Add(5 * time.Minute), future, mockghauth.ErrJWTIssuedInFuture},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, signErr := mockghauth.NewAppJWT(tt.key, tt.issuer, tt.iat, tt.exp)
+			if signErr != nil {
+				t.Fatalf("NewAppJWT() error = %v", signErr)
+			}
+
+			_, verifyErr := apps.VerifyJWT(token, now)
+			if !errors.Is(verifyErr, tt.wantErr) {
+				t.Errorf("Apps.VerifyJWT() error: expected = %v, received = %v", tt.wantErr, verifyErr)
+			}
+		})
+	}
+
+	if _, verifyErr := apps.VerifyJWT("not.a.jwt", now); !errors.Is(verifyErr, mockghauth.ErrJWTMalformed) {
+		t.Errorf("Apps.VerifyJWT() error: expected = %v, received = %v", mockghauth.ErrJWTMalformed, verifyErr)
+	}
+}
@@ -0,0 +1,277 @@
+package mockghauth
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+)
+
+// promptSelectAccount is the only prompt value GitHub supports, forcing the
+// account picker.
+const promptSelectAccount = "select_account"
+
+// authorizeRequest is a validated request to /login/oauth/authorize.
+type authorizeRequest struct {
+	Client              *Client
+	RedirectURI         string
+	State               string
+	Scope               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Login               string
+	Prompt              string
+	AllowSignup         bool
+}
+
+// values returns the request parameters, used to carry the request through
+// the interactive login form. The login is left out as the form's account
+// picker submits the chosen user under that name.
+func (r *authorizeRequest) values() url.Values {
+	out := url.Values{
+		"client_id":    []string{r.Client.ID},
+		"redirect_uri": []string{r.RedirectURI},
+	}
+
+	for k, v := range map[string]string{
+		"state":                 r.State,
+		"scope":                 r.Scope,
+		"code_challenge":        r.CodeChallenge,
+		"code_challenge_method": r.CodeChallengeMethod,
+		"prompt":                r.Prompt,
+	} {
+		if v != "" {
+			out.Set(k, v)
+		}
+	}
+
+	if !r.AllowSignup {
+		out.Set("allow_signup", "false")
+	}
+
+	return out
+}
+
+// authorizePage is the data rendered by the interactive login page.
+type authorizePage struct {
+	AppName     string
+	Action      string
+	Scopes      Scopes
+	Users       []*User
+	Selected    string
+	AllowSignup bool
+	Params      url.Values
+}
+
+func (s *Server) loginOauthAuthorize(c *gin.Context) {
+	req, ok := s.parseAuthorizeRequest(c, c.Request.URL.Query())
+	if !ok {
+		return
+	}
+
+	if s.interactive {
+		s.renderAuthorizePage(c, req)
+		return
+	}
+
+	_, suggested := s.users.Get(req.Login)
+
+	// Without the login page the login parameter chooses the user per request,
+	// falling back to the client's user when it names no fixture user.
+	if suggested {
+		s.completeAuthorize(c, req, req.Login)
+		return
+	}
+
+	s.completeAuthorize(c, req, s.clientLogin(req.Client))
+}
+
+// loginOauthAuthorizeSubmit handles the interactive login form, either
+// approving the request as the chosen user or denying it.
+func (s *Server) loginOauthAuthorizeSubmit(c *gin.Context) {
+	if err := c.Request.ParseForm(); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, NewOAuthError(OAuthErrorInvalidRequest))
+		return
+	}
+
+	req, ok := s.parseAuthorizeRequest(c, c.Request.PostForm)
+	if !ok {
+		return
+	}
+
+	if c.PostForm("cancel") != "" {
+		redirectWithError(c, req.RedirectURI, req.State, NewOAuthError(OAuthErrorAccessDenied))
+		return
+	}
+
+	s.completeAuthorize(c, req, c.PostForm("login"))
+}
+
+// parseAuthorizeRequest validates the authorization request in params. When
+// the request is invalid the error response has already been written and
+// false is returned.
+func (s *Server) parseAuthorizeRequest(c *gin.Context, params url.Values) (*authorizeRequest, bool) {
+	client, clientExists := s.clients.Get(params.Get("client_id"))
+	if !clientExists {
+		c.AbortWithStatusJSON(http.StatusNotFound, NotFoundGitHubAPIError())
+		return nil, false
+	}
+
+	state := params.Get("state")
+
+	redirectURI, err := client.ResolveRedirectURI(params.Get("redirect_uri"))
+	if err != nil {
+		callbacks := client.Callbacks()
+		if len(callbacks) == 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, NewOAuthError(OAuthErrorRedirectURIMismatch))
+			return nil, false
+		}
+
+		redirectWithError(c, callbacks[0], state, NewOAuthError(OAuthErrorRedirectURIMismatch))
+		return nil, false
+	}
+
+	if client.Suspended {
+		redirectWithError(c, redirectURI, state, NewOAuthError(OAuthErrorApplicationSuspended))
+		return nil, false
+	}
+
+	if state == "" && s.requireState {
+		redirectWithError(c, redirectURI, state,
+			NewOAuthError(OAuthErrorInvalidRequest).WithDescription("The state parameter is required."),
+		)
+		return nil, false
+	}
+
+	challenge, challengeMethod := params.Get("code_challenge"), params.Get("code_challenge_method")
+	switch {
+	case challenge == "" && client.RequirePKCE:
+		redirectWithError(c, redirectURI, state,
+			NewOAuthError(OAuthErrorInvalidRequest).WithDescription("This application requires a code_challenge."),
+		)
+		return nil, false
+	case challenge == "" && challengeMethod != "":
+		redirectWithError(c, redirectURI, state,
+			NewOAuthError(OAuthErrorInvalidRequest).WithDescription("The code_challenge parameter is required."),
+		)
+		return nil, false
+	case challenge != "" && challengeMethod == "":
+		challengeMethod = PKCEMethodPlain
+	case challenge != "" && !ValidPKCEMethod(challengeMethod):
+		redirectWithError(c, redirectURI, state,
+			NewOAuthError(OAuthErrorInvalidRequest).WithDescription("The code_challenge_method must be S256 or plain."),
+		)
+		return nil, false
+	}
+
+	return &authorizeRequest{
+		Client:              client,
+		RedirectURI:         redirectURI,
+		State:               state,
+		Scope:               ParseScopes(params.Get("scope")).String(),
+		CodeChallenge:       challenge,
+		CodeChallengeMethod: challengeMethod,
+		Login:               params.Get("login"),
+		Prompt:              params.Get("prompt"),
+		AllowSignup:         params.Get("allow_signup") != "false",
+	}, true
+}
+
+// clientLogin returns the user that logs in to client when no user is chosen.
+func (s *Server) clientLogin(client *Client) string {
+	if client.User != "" {
+		return client.User
+	}
+
+	return s.defaultUser
+}
+
+func (s *Server) renderAuthorizePage(c *gin.Context, req *authorizeRequest) {
+	appName := req.Client.Name
+	if appName == "" {
+		appName = req.Client.ID
+	}
+
+	// A known login is asked for consent as if already signed in, only
+	// prompt=select_account offers the other accounts.
+	users, selected := s.users.List(), s.clientLogin(req.Client)
+	if user, ok := s.users.Get(req.Login); ok {
+		selected = user.Login
+
+		if req.Prompt != promptSelectAccount {
+			users = []*User{user}
+		}
+	}
+
+	c.HTML(http.StatusOK, "authorize.html", &authorizePage{
+		AppName:     appName,
+		Action:      "/login/oauth/authorize",
+		Scopes:      ParseScopes(req.Scope),
+		Users:       users,
+		Selected:    selected,
+		AllowSignup: req.AllowSignup,
+		Params:      req.values(),
+	})
+}
+
+// completeAuthorize issues a code for login and redirects back to the client.
+func (s *Server) completeAuthorize(c *gin.Context, req *authorizeRequest, login string) {
+	user, ok := s.users.Get(login)
+	if !ok {
+		redirectWithError(c, req.RedirectURI, req.State,
+			NewOAuthError(OAuthErrorAccessDenied).WithDescription("The user "+login+" does not exist."),
+		)
+		return
+	}
+
+	code := s.codes.New(&Code{
+		ClientID:            req.Client.ID,
+		RedirectURI:         req.RedirectURI,
+		Scope:               req.Scope,
+		State:               req.State,
+		User:                user.Login,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		AllowSignup:         req.AllowSignup,
+	})
+
+	params := url.Values{"code": []string{code}}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+
+	redirectWithParams(c, req.RedirectURI, params)
+}
+
+// redirectWithError redirects to redirectURI with the error fields and the
+// state, as GitHub does for authorization request errors.
+func redirectWithError(c *gin.Context, redirectURI, state string, oauthErr *GitHubOAuthError) {
+	params := url.Values{
+		"error":             []string{oauthErr.Error},
+		"error_description": []string{oauthErr.ErrorDescription},
+		"error_uri":         []string{oauthErr.ErrorURI},
+	}
+	if state != "" {
+		params.Set("state", state)
+	}
+
+	redirectWithParams(c, redirectURI, params)
+}
+
+// redirectWithParams redirects to redirectURI with params merged into any
+// query string the redirect URI already carries.
+func redirectWithParams(c *gin.Context, redirectURI string, params url.Values) {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, NewOAuthError(OAuthErrorRedirectURIMismatch))
+		return
+	}
+
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	u.RawQuery = q.Encode()
+
+	c.Redirect(http.StatusFound, u.String())
+}
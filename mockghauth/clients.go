@@ -2,8 +2,12 @@ package mockghauth
 
 import (
 	"bytes"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"strings"
 	"sync"
@@ -11,9 +15,22 @@ import (
 	"github.com/oklog/ulid/v2"
 )
 
+// ErrRedirectURIMismatch is returned when a redirect_uri does not match any
+// of the callback URLs registered for a client.
+var ErrRedirectURIMismatch = errors.New("redirect_uri does not match a registered callback URL")
+
 type Client struct {
-	ID     string `json:"id"`
-	Secret string `json:"secret"`
+	ID           string   `json:"id"`
+	Secret       string   `json:"secret"`
+	Name         string   `json:"name,omitempty"`
+	CallbackURL  string   `json:"callback_url,omitempty"`
+	CallbackURLs []string `json:"callback_urls,omitempty"`
+	Suspended    bool     `json:"suspended,omitempty"`
+	RequirePKCE  bool     `json:"require_pkce,omitempty"`
+	User         string   `json:"user,omitempty"`
+	// ExpiringTokens issues GitHub App style expiring user-to-server tokens
+	// with refresh tokens instead of OAuth app tokens.
+	ExpiringTokens bool `json:"expiring_tokens,omitempty"`
 }
 
 func NewClient(id, secret string) *Client {
@@ -28,7 +45,100 @@ func (c *Client) Equal(v *Client) bool {
 		return false
 	}
 
-	return strings.EqualFold(c.ID, v.ID) && strings.EqualFold(c.Secret, v.Secret)
+	return strings.EqualFold(c.ID, v.ID) && c.Secret == v.Secret
+}
+
+// VerifySecret reports whether secret matches the client secret exactly.
+func (c *Client) VerifySecret(secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(c.Secret), []byte(secret)) == 1
+}
+
+// Callbacks returns every callback URL registered for the client, the
+// singular callback_url first.
+func (c *Client) Callbacks() []string {
+	out := make([]string, 0, len(c.CallbackURLs)+1)
+	if c.CallbackURL != "" {
+		out = append(out, c.CallbackURL)
+	}
+
+	return append(out, c.CallbackURLs...)
+}
+
+// ResolveRedirectURI returns the URI the user should be sent back to, the
+// first registered callback when redirectURI is empty.
+func (c *Client) ResolveRedirectURI(redirectURI string) (string, error) {
+	callbacks := c.Callbacks()
+
+	if redirectURI == "" {
+		if len(callbacks) == 0 {
+			return "", ErrRedirectURIMismatch
+		}
+
+		return callbacks[0], nil
+	}
+
+	if len(callbacks) == 0 {
+		return redirectURI, nil
+	}
+
+	for _, callback := range callbacks {
+		if redirectURIMatches(callback, redirectURI) {
+			return redirectURI, nil
+		}
+	}
+
+	return "", ErrRedirectURIMismatch
+}
+
+// redirectURIMatches reports whether redirectURI is acceptable for the
+// registered callback: same scheme and port, the same host or a subdomain of
+// it, and the same path or a sub-path of it. Loopback callbacks accept any
+// port.
+func redirectURIMatches(callback, redirectURI string) bool {
+	cb, cbErr := url.Parse(callback)
+	if cbErr != nil {
+		return false
+	}
+
+	ru, ruErr := url.Parse(redirectURI)
+	if ruErr != nil {
+		return false
+	}
+
+	if !strings.EqualFold(cb.Scheme, ru.Scheme) || ru.User != nil {
+		return false
+	}
+
+	cbHost := strings.ToLower(cb.Hostname())
+	ruHost := strings.ToLower(ru.Hostname())
+	if ruHost != cbHost && !strings.HasSuffix(ruHost, "."+cbHost) {
+		return false
+	}
+
+	if cb.Port() != ru.Port() && !isLoopbackHost(cbHost) {
+		return false
+	}
+
+	for _, segment := range strings.Split(ru.Path, "/") {
+		if segment == ".." {
+			return false
+		}
+	}
+
+	cbPath := strings.TrimSuffix(cb.Path, "/")
+	ruPath := ru.Path
+
+	return ruPath == cbPath || (cbPath == "" && ruPath == "/") || strings.HasPrefix(ruPath, cbPath+"/")
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
 }
 
 func (c *Client) String() string {
@@ -88,6 +198,14 @@ func (c *Clients) Add(id, secret string) {
 	c.clients[id] = NewClient(id, secret)
 }
 
+// Set adds or replaces a client.
+func (c *Clients) Set(client *Client) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.clients[client.ID] = client
+}
+
 func (c *Clients) HasID(id string) bool {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
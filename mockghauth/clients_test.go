@@ -1,6 +1,7 @@
 package mockghauth_test
 
 import (
+	"slices"
 	"testing"
 
 	"github.com/dosquad/mock-oauth-test-server/mockghauth"
@@ -17,7 +18,8 @@ func TestClients_ReadFile(t *testing.T) {
 		{
 			name: "Reading Test Data",
 			fields: map[string]*mockghauth.Client{
-				"test-client": mockghauth.NewClient("test-client", "secret"),
+				"test-client":     mockghauth.NewClient("test-client", "secret"),
+				"callback-client": mockghauth.NewClient("callback-client", "secret"),
 			},
 			filename: "../testdata/clients.json",
 			wantErr:  false,
@@ -51,3 +53,108 @@ func TestClients_ReadFile(t *testing.T) {
 		})
 	}
 }
+
+func TestClients_ReadFileCallbacks(t *testing.T) {
+	c := mockghauth.NewClients()
+
+	if err := c.ReadFile("../testdata/clients.json"); err != nil {
+		t.Fatalf("Clients.ReadFile() error = %v", err)
+	}
+
+	cl, ok := c.Get("callback-client")
+	if !ok {
+		t.Fatalf("Clients.ReadFile() key = callback-client, expected to exist")
+	}
+
+	expect := []string{"http://example.com/path", "http://127.0.0.1/cb"}
+	if got := cl.Callbacks(); !slices.Equal(got, expect) {
+		t.Errorf("Client.Callbacks() = %v, expected = %v", got, expect)
+	}
+}
+
+func TestClient_ResolveRedirectURI(t *testing.T) {
+	tests := []struct {
+		name        string
+		callbacks   []string
+		redirectURI string
+		want        string
+		wantErr     bool
+	}{
+		{"default to callback", []string{"http://example.com/path"}, "", "http://example.com/path", false},
+		{
+			"exact match",
+			[]string{"http://example.com/path"},
+			"http://example.com/path",
+			"http://example.com/path",
+			false,
+		},
+		{
+			"sub-path",
+			[]string{"http://example.com/path"},
+			"http://example.com/path/subdir/other",
+			"http://example.com/path/subdir/other",
+			false,
+		},
+		{
+			"subdomain",
+			[]string{"http://example.com/path"},
+			"http://oauth.example.com/path",
+			"http://oauth.example.com/path",
+			false,
+		},
+		{
+			"query kept",
+			[]string{"http://example.com/path"},
+			"http://example.com/path?a=b",
+			"http://example.com/path?a=b",
+			false,
+		},
+		{
+			"second callback",
+			[]string{"http://example.com/path", "https://app.test/cb"},
+			"https://app.test/cb",
+			"https://app.test/cb",
+			false,
+		},
+		{"other path", []string{"http://example.com/path"}, "http://example.com/bar", "", true},
+		{"root path", []string{"http://example.com/path"}, "http://example.com/", "", true},
+		{"path prefix only", []string{"http://example.com/path"}, "http://example.com/pathological", "", true},
+		{"parent traversal", []string{"http://example.com/path"}, "http://example.com/path/../bar", "", true},
+		{"other port", []string{"http://example.com/path"}, "http://example.com:8080/path", "", true},
+		{"subdomain other port", []string{"http://example.com/path"}, "http://oauth.example.com:8080/path", "", true},
+		{"other host", []string{"http://example.com/path"}, "http://example.org/path", "", true},
+		{"host suffix", []string{"http://example.com/path"}, "http://badexample.com/path", "", true},
+		{"other scheme", []string{"https://example.com/path"}, "http://example.com/path", "", true},
+		{
+			"loopback any port",
+			[]string{"http://127.0.0.1/cb"},
+			"http://127.0.0.1:1234/cb",
+			"http://127.0.0.1:1234/cb",
+			false,
+		},
+		{
+			"localhost any port",
+			[]string{"http://localhost:3000/cb"},
+			"http://localhost:4000/cb",
+			"http://localhost:4000/cb",
+			false,
+		},
+		{"no callbacks", nil, "http://anything.test/cb", "http://anything.test/cb", false},
+		{"no callbacks or redirect", nil, "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl := &mockghauth.Client{ID: "id", Secret: "secret", CallbackURLs: tt.callbacks}
+
+			got, err := cl.ResolveRedirectURI(tt.redirectURI)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Client.ResolveRedirectURI() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if got != tt.want {
+				t.Errorf("Client.ResolveRedirectURI() = %q, expected = %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package mockghauth
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"sync"
@@ -11,27 +12,93 @@ import (
 	"github.com/oklog/ulid/v2"
 )
 
+const (
+	defaultCodeExpire = 10 * time.Minute
+)
+
+var (
+	ErrCodeNotFound         = errors.New("code not found")
+	ErrCodeExpired          = errors.New("code expired")
+	ErrCodeClientMismatch   = errors.New("code issued to a different client")
+	ErrCodeVerifierMismatch = errors.New("code_verifier does not match code_challenge")
+)
+
+// Code is an issued authorization code and the authorization request it was
+// issued for.
+type Code struct {
+	ClientID            string    `json:"client_id,omitempty"`
+	RedirectURI         string    `json:"redirect_uri,omitempty"`
+	Scope               string    `json:"scope,omitempty"`
+	State               string    `json:"state,omitempty"`
+	User                string    `json:"user,omitempty"`
+	CodeChallenge       string    `json:"code_challenge,omitempty"`
+	CodeChallengeMethod string    `json:"code_challenge_method,omitempty"`
+	AllowSignup         bool      `json:"allow_signup"`
+	CreatedAt           time.Time `json:"created_at"`
+	ExpiresAt           time.Time `json:"expires_at,omitzero"`
+}
+
+// UnmarshalJSON accepts either a code object or, for older code files, a bare
+// creation timestamp.
+func (c *Code) UnmarshalJSON(data []byte) error {
+	var ts time.Time
+	if err := json.Unmarshal(data, &ts); err == nil {
+		*c = Code{CreatedAt: ts}
+		return nil
+	}
+
+	type plainCode Code
+
+	var v plainCode
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*c = Code(v)
+
+	return nil
+}
+
+// Expired reports whether the code has an expiry and it is before ts.
+func (c *Code) Expired(ts time.Time) bool {
+	return !c.ExpiresAt.IsZero() && c.ExpiresAt.Before(ts)
+}
+
 type Codes struct {
-	lock  sync.RWMutex
-	codes map[string]time.Time
+	lock   sync.RWMutex
+	expire time.Duration
+	codes  map[string]*Code
 }
 
 func (c *Codes) checkMap() {
+	if c.expire == 0 {
+		c.expire = defaultCodeExpire
+	}
+
 	if c.codes != nil {
 		return
 	}
 
-	c.codes = make(map[string]time.Time)
+	c.codes = make(map[string]*Code)
+}
+
+func (c *Codes) SetExpire(exp time.Duration) {
+	c.expire = exp
 }
 
-func (c *Codes) New() string {
+// New stores a copy of code with its creation and expiry times set and
+// returns the generated code value.
+func (c *Codes) New(code *Code) string {
 	id := ulid.Make()
 
 	c.lock.Lock()
 	defer c.lock.Unlock()
 	c.checkMap()
 
-	c.codes[id.String()] = time.Now()
+	v := *code
+	v.CreatedAt = time.Now()
+	v.ExpiresAt = v.CreatedAt.Add(c.expire)
+	c.codes[id.String()] = &v
 
 	return id.String()
 }
@@ -59,12 +126,13 @@ func (c *Codes) WriteFile(filename string) error {
 	return os.WriteFile(filename, buf.Bytes(), 0o600)
 }
 
+// Add stores a code that is not bound to a client and does not expire.
 func (c *Codes) Add(code string) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 
 	c.checkMap()
-	c.codes[code] = time.Now()
+	c.codes[code] = &Code{CreatedAt: time.Now()}
 }
 
 func (c *Codes) Delete(code string) {
@@ -75,16 +143,17 @@ func (c *Codes) Delete(code string) {
 	delete(c.codes, code)
 }
 
-func (c *Codes) Get(code string) (time.Time, bool) {
+func (c *Codes) Get(code string) (*Code, bool) {
 	c.lock.RLock()
 	defer c.lock.RUnlock()
 
 	c.checkMap()
 	if v, ok := c.codes[code]; ok {
-		return v, true
+		out := *v
+		return &out, true
 	}
 
-	return time.Time{}, false
+	return nil, false
 }
 
 func (c *Codes) Exists(code string) bool {
@@ -96,3 +165,52 @@ func (c *Codes) Exists(code string) bool {
 
 	return ok
 }
+
+// Exchange consumes the code in req on behalf of req.ClientID and returns the
+// request it was issued for.
+func (c *Codes) Exchange(req *GitHubOAuth) (*Code, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.checkMap()
+
+	v, ok := c.codes[req.Code]
+	if !ok {
+		return nil, ErrCodeNotFound
+	}
+
+	if v.Expired(time.Now()) {
+		delete(c.codes, req.Code)
+		return nil, ErrCodeExpired
+	}
+
+	if v.ClientID != "" && v.ClientID != req.ClientID {
+		return nil, ErrCodeClientMismatch
+	}
+
+	if req.RedirectURI != "" && v.RedirectURI != "" && v.RedirectURI != req.RedirectURI {
+		return nil, ErrRedirectURIMismatch
+	}
+
+	if v.CodeChallenge != "" && !VerifyPKCE(v.CodeChallenge, v.CodeChallengeMethod, req.CodeVerifier) {
+		return nil, ErrCodeVerifierMismatch
+	}
+
+	delete(c.codes, req.Code)
+
+	return v, nil
+}
+
+// Reaper removes every code that expired before ts.
+func (c *Codes) Reaper(ts time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.checkMap()
+
+	for k, v := range c.codes {
+		if v.Expired(ts) {
+			delete(c.codes, k)
+		}
+	}
+}
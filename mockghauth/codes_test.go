@@ -1,6 +1,7 @@
 package mockghauth_test
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -36,16 +37,80 @@ func TestCodes_ReadFile(t *testing.T) {
 			}
 
 			for k, v := range tt.fields {
-				ts, ok := c.Get(k)
+				code, ok := c.Get(k)
 				if !ok {
 					t.Errorf("Codes.ReadFile() key = %s, expected to exist", k)
 					return
 				}
 
-				if !v.Equal(ts) {
-					t.Errorf("Codes.ReadFile() key = %s, expected = %s, received = %s", k, v.String(), ts.String())
+				if !v.Equal(code.CreatedAt) {
+					t.Errorf(
+						"Codes.ReadFile() key = %s, expected = %s, received = %s",
+						k, v.String(), code.CreatedAt.String(),
+					)
 				}
 			}
 		})
 	}
 }
+
+func TestCodes_Exchange(t *testing.T) {
+	tests := []struct {
+		name        string
+		expire      time.Duration
+		clientID    string
+		redirectURI string
+		wantErr     error
+		wantKept    bool
+	}{
+		{"valid exchange", time.Minute, "client-a", "", nil, false},
+		{"matching redirect_uri", time.Minute, "client-a", "http://localhost/cb", nil, false},
+		{"expired", -time.Second, "client-a", "", mockghauth.ErrCodeExpired, false},
+		{"different client", time.Minute, "client-b", "", mockghauth.ErrCodeClientMismatch, true},
+		{
+			"different redirect_uri", time.Minute, "client-a", "http://localhost/other",
+			mockghauth.ErrRedirectURIMismatch, true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &mockghauth.Codes{}
+			c.SetExpire(tt.expire)
+
+			code := c.New(&mockghauth.Code{
+				ClientID:    "client-a",
+				RedirectURI: "http://localhost/cb",
+				Scope:       "repo",
+				State:       "state",
+				User:        "octocat",
+			})
+
+			got, err := c.Exchange(&mockghauth.GitHubOAuth{
+				ClientID:    tt.clientID,
+				Code:        code,
+				RedirectURI: tt.redirectURI,
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Codes.Exchange() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if err == nil && (got.Scope != "repo" || got.State != "state" || got.User != "octocat") {
+				t.Errorf("Codes.Exchange() = %+v, expected request details to be recorded", got)
+			}
+
+			if c.Exists(code) != tt.wantKept {
+				t.Errorf("Codes.Exchange() code exists = %t, expected = %t", c.Exists(code), tt.wantKept)
+			}
+
+			if tt.wantKept {
+				return
+			}
+
+			_, err = c.Exchange(&mockghauth.GitHubOAuth{ClientID: "client-a", Code: code})
+			if !errors.Is(err, mockghauth.ErrCodeNotFound) {
+				t.Errorf("Codes.Exchange() reuse error = %v, wantErr %v", err, mockghauth.ErrCodeNotFound)
+			}
+		})
+	}
+}
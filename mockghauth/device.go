@@ -0,0 +1,213 @@
+package mockghauth
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const grantTypeDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
+
+// Device activation page states.
+const (
+	devicePageEnter    = "enter"
+	devicePageConfirm  = "confirm"
+	devicePageApproved = "approved"
+	devicePageDenied   = "denied"
+)
+
+// devicePage is the data rendered by the device activation page.
+type devicePage struct {
+	State    string
+	Error    string
+	UserCode string
+	AppName  string
+	Scopes   Scopes
+	Users    []*User
+	Selected string
+}
+
+// loginDeviceCode starts the device flow for a client, returning the device
+// code it polls with and the user code the user enters.
+func (s *Server) loginDeviceCode(c *gin.Context) {
+	var oauthReq GitHubOAuth
+
+	if err := bindOAuthRequest(c, &oauthReq); err != nil {
+		renderOAuth(c, http.StatusBadRequest,
+			NewOAuthError(OAuthErrorInvalidRequest).WithDescription("The request could not be parsed: "+err.Error()),
+		)
+		return
+	}
+
+	client, clientExists := s.clients.Get(oauthReq.ClientID)
+	if !clientExists {
+		renderOAuth(c, http.StatusOK, NewOAuthError(OAuthErrorIncorrectClientCredentials))
+		return
+	}
+
+	if client.Suspended {
+		renderOAuth(c, http.StatusOK, NewOAuthError(OAuthErrorApplicationSuspended))
+		return
+	}
+
+	deviceCode, code := s.devices.New(&DeviceCode{
+		ClientID: client.ID,
+		Scope:    ParseScopes(oauthReq.Scope).String(),
+	})
+
+	renderOAuth(c, http.StatusOK, &GitHubDeviceCodeResponse{
+		DeviceCode:      deviceCode,
+		UserCode:        code.UserCode,
+		VerificationURI: urlMustResolve(s.baseURL, "/login/device").String(),
+		ExpiresIn:       int(code.ExpiresAt.Sub(code.CreatedAt).Seconds()),
+		Interval:        int(code.Interval.Seconds()),
+	})
+}
+
+// loginDevice renders the device activation page, asking for the user code
+// or, once entered, which user approves it.
+func (s *Server) loginDevice(c *gin.Context) {
+	userCode := c.Query("user_code")
+	if userCode == "" {
+		c.HTML(http.StatusOK, "device.html", &devicePage{State: devicePageEnter})
+		return
+	}
+
+	code, ok := s.devices.GetByUserCode(userCode)
+	if !ok || code.Status != DeviceCodePending {
+		s.renderDeviceError(c, userCode)
+		return
+	}
+
+	appName := code.ClientID
+	selected := s.defaultUser
+
+	if client, clientExists := s.clients.Get(code.ClientID); clientExists {
+		selected = s.clientLogin(client)
+		if client.Name != "" {
+			appName = client.Name
+		}
+	}
+
+	c.HTML(http.StatusOK, "device.html", &devicePage{
+		State:    devicePageConfirm,
+		UserCode: code.UserCode,
+		AppName:  appName,
+		Scopes:   ParseScopes(code.Scope),
+		Users:    s.users.List(),
+		Selected: selected,
+	})
+}
+
+// loginDeviceSubmit handles the device activation form, approving the user
+// code as the chosen user or denying it.
+func (s *Server) loginDeviceSubmit(c *gin.Context) {
+	userCode := c.PostForm("user_code")
+
+	if c.PostForm("cancel") != "" {
+		if err := s.devices.Deny(userCode); err != nil {
+			s.renderDeviceError(c, userCode)
+			return
+		}
+
+		c.HTML(http.StatusOK, "device.html", &devicePage{State: devicePageDenied, UserCode: userCode})
+		return
+	}
+
+	if err := s.ApproveDeviceCode(userCode, c.PostForm("login")); err != nil {
+		s.renderDeviceError(c, userCode)
+		return
+	}
+
+	c.HTML(http.StatusOK, "device.html", &devicePage{State: devicePageApproved, UserCode: userCode})
+}
+
+func (s *Server) renderDeviceError(c *gin.Context, userCode string) {
+	c.HTML(http.StatusNotFound, "device.html", &devicePage{
+		State:    devicePageEnter,
+		UserCode: userCode,
+		Error:    "The code you entered is invalid or has expired.",
+	})
+}
+
+// grantDeviceCode polls a device code, issuing a token once the user has
+// approved it.
+func (s *Server) grantDeviceCode(c *gin.Context, client *Client, oauthReq *GitHubOAuth) {
+	code, err := s.devices.Poll(oauthReq.DeviceCode, client.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrDeviceCodePending):
+			renderOAuth(c, http.StatusOK, NewOAuthError(OAuthErrorAuthorizationPending))
+		case errors.Is(err, ErrDeviceCodeSlowDown):
+			renderOAuth(c, http.StatusOK, NewOAuthError(OAuthErrorSlowDown).WithInterval(code.Interval))
+		case errors.Is(err, ErrDeviceCodeExpired):
+			renderOAuth(c, http.StatusOK, NewOAuthError(OAuthErrorExpiredToken))
+		case errors.Is(err, ErrDeviceCodeDenied):
+			renderOAuth(c, http.StatusOK, NewOAuthError(OAuthErrorAccessDenied))
+		default:
+			renderOAuth(c, http.StatusOK, NewOAuthError(OAuthErrorIncorrectDeviceCode))
+		}
+		return
+	}
+
+	renderOAuth(c, http.StatusOK, s.issueToken(client, code.User, ParseScopes(code.Scope)))
+}
+
+// mockDeviceApprove approves a user code without the activation page, as the
+// login in the query or form, or the client's default user.
+func (s *Server) mockDeviceApprove(c *gin.Context) {
+	userCode := c.Param("user_code")
+
+	login := c.Query("login")
+	if login == "" {
+		login = c.PostForm("login")
+	}
+
+	if login == "" {
+		code, ok := s.devices.GetByUserCode(userCode)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusNotFound, NotFoundGitHubAPIError())
+			return
+		}
+
+		login = s.defaultUser
+		if client, clientExists := s.clients.Get(code.ClientID); clientExists {
+			login = s.clientLogin(client)
+		}
+	}
+
+	if err := s.ApproveDeviceCode(userCode, login); err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, NotFoundGitHubAPIError())
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// mockDeviceDeny denies a user code without the activation page.
+func (s *Server) mockDeviceDeny(c *gin.Context) {
+	if err := s.DenyDeviceCode(c.Param("user_code")); err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, NotFoundGitHubAPIError())
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ApproveDeviceCode approves the pending device flow user code as login, the
+// next poll for it issues a token.
+func (s *Server) ApproveDeviceCode(userCode, login string) error {
+	user, ok := s.users.Get(login)
+	if !ok {
+		return ErrUserNotFound
+	}
+
+	return s.devices.Approve(userCode, user.Login)
+}
+
+// DenyDeviceCode denies the pending device flow user code, the next poll for
+// it returns access_denied.
+func (s *Server) DenyDeviceCode(userCode string) error {
+	return s.devices.Deny(userCode)
+}
@@ -0,0 +1,278 @@
+package mockghauth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDeviceCodeExpire   = 15 * time.Minute
+	defaultDeviceCodeInterval = 5 * time.Second
+	deviceSlowDownPenalty     = 5 * time.Second
+
+	// userCodeAlphabet avoids vowels and easily confused characters, the user
+	// code is read off one screen and typed into another.
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength   = 8
+	deviceCodeBytes  = 20
+)
+
+var (
+	ErrDeviceCodeNotFound       = errors.New("device code not found")
+	ErrDeviceCodeExpired        = errors.New("device code expired")
+	ErrDeviceCodeClientMismatch = errors.New("device code issued to a different client")
+	ErrDeviceCodePending        = errors.New("device code authorization pending")
+	ErrDeviceCodeSlowDown       = errors.New("device code polled too often")
+	ErrDeviceCodeDenied         = errors.New("device code denied")
+)
+
+// DeviceCodeStatus is where a device code is in the device flow.
+type DeviceCodeStatus string
+
+const (
+	DeviceCodePending  DeviceCodeStatus = "pending"
+	DeviceCodeApproved DeviceCodeStatus = "approved"
+	DeviceCodeDenied   DeviceCodeStatus = "denied"
+)
+
+// DeviceCode is an issued device flow code and the state of its authorization.
+type DeviceCode struct {
+	ClientID     string           `json:"client_id,omitempty"`
+	Scope        string           `json:"scope,omitempty"`
+	UserCode     string           `json:"user_code"`
+	User         string           `json:"user,omitempty"`
+	Status       DeviceCodeStatus `json:"status"`
+	Interval     time.Duration    `json:"interval"`
+	CreatedAt    time.Time        `json:"created_at"`
+	ExpiresAt    time.Time        `json:"expires_at"`
+	LastPolledAt time.Time        `json:"last_polled_at,omitzero"`
+}
+
+// Expired reports whether the device code expired before ts.
+func (d *DeviceCode) Expired(ts time.Time) bool {
+	return !d.ExpiresAt.IsZero() && d.ExpiresAt.Before(ts)
+}
+
+type DeviceCodes struct {
+	lock     sync.RWMutex
+	expire   time.Duration
+	interval time.Duration
+	codes    map[string]*DeviceCode
+}
+
+func (d *DeviceCodes) checkMap() {
+	if d.expire == 0 {
+		d.expire = defaultDeviceCodeExpire
+	}
+
+	if d.interval == 0 {
+		d.interval = defaultDeviceCodeInterval
+	}
+
+	if d.codes != nil {
+		return
+	}
+
+	d.codes = make(map[string]*DeviceCode)
+}
+
+func (d *DeviceCodes) SetExpire(exp time.Duration) {
+	d.expire = exp
+}
+
+// SetInterval sets the minimum time clients must wait between polls.
+func (d *DeviceCodes) SetInterval(interval time.Duration) {
+	d.interval = interval
+}
+
+// New stores a pending copy of code with a generated user code, interval and
+// expiry, and returns the generated device code.
+func (d *DeviceCodes) New(code *DeviceCode) (string, *DeviceCode) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.checkMap()
+
+	deviceCode := newDeviceCode()
+
+	v := *code
+	v.UserCode = d.newUserCode()
+	v.Status = DeviceCodePending
+	v.Interval = d.interval
+	v.CreatedAt = time.Now()
+	v.ExpiresAt = v.CreatedAt.Add(d.expire)
+	d.codes[deviceCode] = &v
+
+	out := v
+
+	return deviceCode, &out
+}
+
+// newUserCode returns a user code in GitHub's XXXX-XXXX form that is not
+// already in use. The lock must be held.
+func (d *DeviceCodes) newUserCode() string {
+	for {
+		buf := make([]byte, userCodeLength)
+		_, _ = rand.Read(buf)
+
+		var sb strings.Builder
+		for i, b := range buf {
+			if i == userCodeLength/2 {
+				sb.WriteByte('-')
+			}
+
+			sb.WriteByte(userCodeAlphabet[int(b)%len(userCodeAlphabet)])
+		}
+
+		if _, exists := d.findUserCode(sb.String()); !exists {
+			return sb.String()
+		}
+	}
+}
+
+// findUserCode returns the device code for userCode. The lock must be held.
+func (d *DeviceCodes) findUserCode(userCode string) (string, bool) {
+	userCode = normaliseUserCode(userCode)
+
+	for k, v := range d.codes {
+		if v.UserCode == userCode {
+			return k, true
+		}
+	}
+
+	return "", false
+}
+
+// GetByUserCode returns the device code a user entered, ignoring case and
+// the separating dash.
+func (d *DeviceCodes) GetByUserCode(userCode string) (*DeviceCode, bool) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	d.checkMap()
+
+	k, ok := d.findUserCode(userCode)
+	if !ok {
+		return nil, false
+	}
+
+	out := *d.codes[k]
+
+	return &out, true
+}
+
+// Approve marks the pending device code for userCode as approved by login.
+func (d *DeviceCodes) Approve(userCode, login string) error {
+	return d.resolve(userCode, DeviceCodeApproved, login)
+}
+
+// Deny marks the pending device code for userCode as denied.
+func (d *DeviceCodes) Deny(userCode string) error {
+	return d.resolve(userCode, DeviceCodeDenied, "")
+}
+
+func (d *DeviceCodes) resolve(userCode string, status DeviceCodeStatus, login string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.checkMap()
+
+	k, ok := d.findUserCode(userCode)
+	if !ok || d.codes[k].Status != DeviceCodePending {
+		return ErrDeviceCodeNotFound
+	}
+
+	if d.codes[k].Expired(time.Now()) {
+		return ErrDeviceCodeExpired
+	}
+
+	d.codes[k].Status = status
+	d.codes[k].User = login
+
+	return nil
+}
+
+// Poll checks the device code on behalf of clientID, removing and returning
+// it once approved.
+func (d *DeviceCodes) Poll(deviceCode, clientID string) (*DeviceCode, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.checkMap()
+
+	v, ok := d.codes[deviceCode]
+	if !ok {
+		return nil, ErrDeviceCodeNotFound
+	}
+
+	if v.ClientID != "" && v.ClientID != clientID {
+		return nil, ErrDeviceCodeClientMismatch
+	}
+
+	now := time.Now()
+
+	if v.Expired(now) {
+		delete(d.codes, deviceCode)
+		return nil, ErrDeviceCodeExpired
+	}
+
+	switch v.Status {
+	case DeviceCodeApproved:
+		delete(d.codes, deviceCode)
+		return v, nil
+	case DeviceCodeDenied:
+		delete(d.codes, deviceCode)
+		return nil, ErrDeviceCodeDenied
+	case DeviceCodePending:
+	}
+
+	tooSoon := !v.LastPolledAt.IsZero() && now.Sub(v.LastPolledAt) < v.Interval
+	v.LastPolledAt = now
+
+	out := *v
+
+	if tooSoon {
+		v.Interval += deviceSlowDownPenalty
+		out.Interval = v.Interval
+
+		return &out, ErrDeviceCodeSlowDown
+	}
+
+	return &out, ErrDeviceCodePending
+}
+
+// Reaper removes every device code that expired before ts.
+func (d *DeviceCodes) Reaper(ts time.Time) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.checkMap()
+
+	for k, v := range d.codes {
+		if v.Expired(ts) {
+			delete(d.codes, k)
+		}
+	}
+}
+
+func newDeviceCode() string {
+	buf := make([]byte, deviceCodeBytes)
+	_, _ = rand.Read(buf)
+
+	return hex.EncodeToString(buf)
+}
+
+// normaliseUserCode upper-cases a user code and restores the dash, so codes
+// typed as "wdjbmjht" still match.
+func normaliseUserCode(userCode string) string {
+	userCode = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(userCode), "-", ""))
+	if len(userCode) != userCodeLength {
+		return userCode
+	}
+
+	return userCode[:userCodeLength/2] + "-" + userCode[userCodeLength/2:]
+}
@@ -0,0 +1,94 @@
+package mockghauth_test
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dosquad/mock-oauth-test-server/mockghauth"
+)
+
+func TestDeviceCodes_New(t *testing.T) {
+	dc := &mockghauth.DeviceCodes{}
+
+	deviceCode, code := dc.New(&mockghauth.DeviceCode{ClientID: "test-client"})
+
+	if !regexp.MustCompile(`^[0-9a-f]{40}$`).MatchString(deviceCode) {
+		t.Errorf("DeviceCodes.New() device code = %q, expected 40 hex characters", deviceCode)
+	}
+
+	if !regexp.MustCompile(`^[A-Z]{4}-[A-Z]{4}$`).MatchString(code.UserCode) {
+		t.Errorf("DeviceCodes.New() user code = %q, expected XXXX-XXXX", code.UserCode)
+	}
+
+	if code.Status != mockghauth.DeviceCodePending {
+		t.Errorf("DeviceCodes.New() status: expected = %q, received = %q", mockghauth.DeviceCodePending, code.Status)
+	}
+
+	typed := strings.ToLower(strings.ReplaceAll(code.UserCode, "-", ""))
+	if _, ok := dc.GetByUserCode(typed); !ok {
+		t.Errorf("DeviceCodes.GetByUserCode(%q) expected to find %q", typed, code.UserCode)
+	}
+}
+
+func TestDeviceCodes_Poll(t *testing.T) {
+	dc := &mockghauth.DeviceCodes{}
+	dc.SetInterval(time.Hour)
+
+	deviceCode, code := dc.New(&mockghauth.DeviceCode{ClientID: "test-client"})
+
+	tests := []struct {
+		name         string
+		clientID     string
+		approve      bool
+		wantErr      error
+		wantInterval time.Duration
+	}{
+		{"other client", "other-client", false, mockghauth.ErrDeviceCodeClientMismatch, 0},
+		{"first poll", "test-client", false, mockghauth.ErrDeviceCodePending, time.Hour},
+		{"poll too soon", "test-client", false, mockghauth.ErrDeviceCodeSlowDown, time.Hour + 5*time.Second},
+		{"approved", "test-client", true, nil, time.Hour + 5*time.Second},
+		{"already used", "test-client", false, mockghauth.ErrDeviceCodeNotFound, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.approve {
+				if err := dc.Approve(code.UserCode, "octocat"); err != nil {
+					t.Fatalf("DeviceCodes.Approve() error = %v", err)
+				}
+			}
+
+			got, err := dc.Poll(deviceCode, tt.clientID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("DeviceCodes.Poll() error: expected = %v, received = %v", tt.wantErr, err)
+			}
+
+			if tt.wantInterval != 0 && got.Interval != tt.wantInterval {
+				t.Errorf("DeviceCodes.Poll() interval: expected = %s, received = %s", tt.wantInterval, got.Interval)
+			}
+		})
+	}
+}
+
+func TestDeviceCodes_Expired(t *testing.T) {
+	dc := &mockghauth.DeviceCodes{}
+	dc.SetExpire(time.Millisecond)
+
+	deviceCode, code := dc.New(&mockghauth.DeviceCode{ClientID: "test-client"})
+
+	time.Sleep(5 * time.Millisecond)
+
+	if err := dc.Approve(code.UserCode, "octocat"); !errors.Is(err, mockghauth.ErrDeviceCodeExpired) {
+		t.Errorf("DeviceCodes.Approve() error: expected = %v, received = %v", mockghauth.ErrDeviceCodeExpired, err)
+	}
+
+	if _, err := dc.Poll(deviceCode, "test-client"); !errors.Is(err, mockghauth.ErrDeviceCodeExpired) {
+		t.Errorf("DeviceCodes.Poll() error: expected = %v, received = %v", mockghauth.ErrDeviceCodeExpired, err)
+	}
+
+	if _, err := dc.Poll(deviceCode, "test-client"); !errors.Is(err, mockghauth.ErrDeviceCodeNotFound) {
+		t.Errorf("DeviceCodes.Poll() error: expected = %v, received = %v", mockghauth.ErrDeviceCodeNotFound, err)
+	}
+}
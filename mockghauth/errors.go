@@ -0,0 +1,99 @@
+package mockghauth
+
+import "time"
+
+// OAuth error codes used by the authorization and token endpoints.
+const (
+	OAuthErrorAccessDenied               = "access_denied"
+	OAuthErrorApplicationSuspended       = "application_suspended"
+	OAuthErrorAuthorizationPending       = "authorization_pending"
+	OAuthErrorBadRefreshToken            = "bad_refresh_token"
+	OAuthErrorBadVerificationCode        = "bad_verification_code"
+	OAuthErrorExpiredToken               = "expired_token"
+	OAuthErrorIncorrectDeviceCode        = "incorrect_device_code"
+	OAuthErrorIncorrectClientCredentials = "incorrect_client_credentials"
+	OAuthErrorInvalidRequest             = "invalid_request"
+	OAuthErrorRedirectURIMismatch        = "redirect_uri_mismatch"
+	OAuthErrorSlowDown                   = "slow_down"
+	OAuthErrorUnsupportedGrantType       = "unsupported_grant_type"
+	OAuthErrorUnverifiedUserEmail        = "unverified_user_email"
+)
+
+const (
+	oauthAppsDocsURI    = "https://docs.github.com/apps/managing-oauth-apps/"
+	authorizeErrorURI   = oauthAppsDocsURI + "troubleshooting-authorization-request-errors"
+	accessTokenErrorURI = oauthAppsDocsURI + "troubleshooting-oauth-app-access-token-request-errors"
+	deviceFlowErrorURI  = "https://docs.github.com/apps/oauth-apps/building-oauth-apps/" +
+		"authorizing-oauth-apps#error-codes-for-the-device-flow"
+	refreshTokenDocsURI = "https://docs.github.com/apps/creating-github-apps/" +
+		"authenticating-with-a-github-app/refreshing-user-access-tokens"
+)
+
+// NewOAuthError returns the error GitHub sends for code, with its standard
+// description and documentation URI.
+func NewOAuthError(code string) *GitHubOAuthError {
+	out := &GitHubOAuthError{
+		Error: code,
+	}
+
+	switch code {
+	case OAuthErrorAccessDenied:
+		out.ErrorDescription = "The user has denied your application access."
+		out.ErrorURI = authorizeErrorURI + "#access-denied"
+	case OAuthErrorApplicationSuspended:
+		out.ErrorDescription = "Your application has been suspended. Contact support@github.com."
+		out.ErrorURI = authorizeErrorURI + "#application-suspended"
+	case OAuthErrorAuthorizationPending:
+		out.ErrorDescription = "The authorization request is still pending."
+		out.ErrorURI = deviceFlowErrorURI
+	case OAuthErrorBadRefreshToken:
+		out.ErrorDescription = "The refresh token passed is incorrect or expired."
+		out.ErrorURI = refreshTokenDocsURI
+	case OAuthErrorBadVerificationCode:
+		out.ErrorDescription = "The code passed is incorrect or expired."
+		out.ErrorURI = accessTokenErrorURI + "#bad-verification-code"
+	case OAuthErrorExpiredToken:
+		out.ErrorDescription = "The device_code has expired."
+		out.ErrorURI = deviceFlowErrorURI
+	case OAuthErrorIncorrectDeviceCode:
+		out.ErrorDescription = "The device_code provided is not valid."
+		out.ErrorURI = deviceFlowErrorURI
+	case OAuthErrorIncorrectClientCredentials:
+		out.ErrorDescription = "The client_id and/or client_secret passed are incorrect."
+		out.ErrorURI = accessTokenErrorURI + "#incorrect-client-credentials"
+	case OAuthErrorRedirectURIMismatch:
+		out.ErrorDescription = "The redirect_uri MUST match the registered callback URL for this application."
+		out.ErrorURI = authorizeErrorURI + "#redirect-uri-mismatch"
+	case OAuthErrorSlowDown:
+		out.ErrorDescription = "Too many requests have been made in the same timeframe."
+		out.ErrorURI = deviceFlowErrorURI
+	case OAuthErrorUnsupportedGrantType:
+		out.ErrorDescription = "The grant type is not supported."
+		out.ErrorURI = accessTokenErrorURI
+	case OAuthErrorUnverifiedUserEmail:
+		out.ErrorDescription = "The user must have a verified primary email."
+		out.ErrorURI = accessTokenErrorURI + "#unverified-user-email"
+	default:
+		out.ErrorDescription = "The request is missing a required parameter or is otherwise malformed."
+		out.ErrorURI = authorizeErrorURI
+	}
+
+	return out
+}
+
+// WithDescription returns a copy of the error with a different description.
+func (e *GitHubOAuthError) WithDescription(description string) *GitHubOAuthError {
+	out := *e
+	out.ErrorDescription = description
+
+	return &out
+}
+
+// WithInterval returns a copy of the error carrying the polling interval, as
+// the device flow slow_down error does.
+func (e *GitHubOAuthError) WithInterval(interval time.Duration) *GitHubOAuthError {
+	out := *e
+	out.Interval = int(interval.Seconds())
+
+	return &out
+}
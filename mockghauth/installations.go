@@ -0,0 +1,395 @@
+package mockghauth
+
+import (
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	appContextKey = "mockghauth.app"
+
+	installationTokenExpire = time.Hour
+
+	repositorySelectionAll      = "all"
+	repositorySelectionSelected = "selected"
+)
+
+// installationTokenRequest is the optional body of an installation access
+// token request, narrowing what the token may access.
+type installationTokenRequest struct {
+	Repositories []string          `json:"repositories"`
+	Permissions  map[string]string `json:"permissions"`
+}
+
+// requireAppAuth authenticates the request with a GitHub App JWT bearer
+// token, storing the app in the context.
+func (s *Server) requireAppAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scheme, token, _ := strings.Cut(c.Request.Header.Get("Authorization"), " ")
+		if token == "" || !strings.EqualFold(scheme, "bearer") {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, UnauthorizedGitHubAPIError())
+			return
+		}
+
+		app, err := s.apps.VerifyJWT(token, time.Now())
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, UnauthorizedGitHubAPIError(appJWTErrorMessage(err)))
+			return
+		}
+
+		c.Set(appContextKey, app)
+		c.Next()
+	}
+}
+
+// appJWTErrorMessage returns the message GitHub responds with when a JWT is
+// rejected for err.
+func appJWTErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, ErrJWTIssuer):
+		return "Integration not found"
+	case errors.Is(err, ErrJWTIssuedInFuture):
+		return "'Issued at' claim ('iat') must be an Integer representing a time in the past"
+	case errors.Is(err, ErrJWTExpired):
+		return "'Expiration time' claim ('exp') must be a numeric value representing the future time at which " +
+			"the assertion expires"
+	case errors.Is(err, ErrJWTExpiryTooFar):
+		return "'Expiration time' claim ('exp') is too far in the future"
+	}
+
+	return "A JSON web token could not be decoded"
+}
+
+// contextApp returns the app stored by requireAppAuth.
+func contextApp(c *gin.Context) *App {
+	if v, ok := c.Get(appContextKey); ok {
+		if app, isApp := v.(*App); isApp {
+			return app
+		}
+	}
+
+	return nil
+}
+
+// contextInstallation returns the authenticated app's installation in the
+// path.
+func contextInstallation(c *gin.Context) (*App, *Installation, bool) {
+	app := contextApp(c)
+
+	id, err := strconv.ParseInt(c.Param("installation_id"), 10, 64)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, NotFoundGitHubAPIError())
+		return nil, nil, false
+	}
+
+	installation, ok := app.Installation(id)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusNotFound, NotFoundGitHubAPIError())
+		return nil, nil, false
+	}
+
+	return app, installation, true
+}
+
+func (s *Server) apiV3App(c *gin.Context) {
+	c.JSON(http.StatusOK, s.githubApp(contextApp(c)))
+}
+
+func (s *Server) apiV3AppInstallations(c *gin.Context) {
+	app := contextApp(c)
+
+	out := make([]*GitHubInstallation, 0, len(app.Installations))
+	for _, v := range app.Installations {
+		out = append(out, s.githubInstallation(app, v))
+	}
+
+	c.JSON(http.StatusOK, out)
+}
+
+func (s *Server) apiV3AppInstallation(c *gin.Context) {
+	app, installation, ok := contextInstallation(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, s.githubInstallation(app, installation))
+}
+
+// apiV3AppInstallationAccessTokens mints a ghs_ installation access token,
+// limited to the requested repositories and permissions when given.
+func (s *Server) apiV3AppInstallationAccessTokens(c *gin.Context) {
+	app, installation, ok := contextInstallation(c)
+	if !ok {
+		return
+	}
+
+	if installation.Suspended {
+		c.AbortWithStatusJSON(http.StatusForbidden, &GitHubAPIError{
+			Message:          "This installation has been suspended",
+			DocumentationURL: apiDocumentationURL,
+		})
+		return
+	}
+
+	var req installationTokenRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, &GitHubAPIError{
+				Message:          "Problems parsing JSON",
+				DocumentationURL: apiDocumentationURL,
+			})
+			return
+		}
+	}
+
+	permissions := maps.Clone(installation.Permissions)
+	if len(req.Permissions) > 0 {
+		for name, level := range req.Permissions {
+			if !permissionGrants(installation.Permissions[name], level) {
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, &GitHubAPIError{
+					Message:          "The permissions requested are not granted to this installation.",
+					DocumentationURL: apiDocumentationURL,
+				})
+				return
+			}
+		}
+
+		permissions = req.Permissions
+	}
+
+	selection := installation.RepositorySelection
+	if selection == "" {
+		selection = repositorySelectionAll
+	}
+
+	repositories := installation.Repositories
+	if len(req.Repositories) > 0 {
+		for _, repo := range req.Repositories {
+			if !installation.HasRepository(repo) {
+				c.AbortWithStatusJSON(http.StatusUnprocessableEntity, &GitHubAPIError{
+					Message: "There is at least one repository that does not exist or is not accessible " +
+						"to the parent installation.",
+					DocumentationURL: apiDocumentationURL,
+				})
+				return
+			}
+		}
+
+		selection = repositorySelectionSelected
+		repositories = req.Repositories
+	}
+
+	if selection != repositorySelectionSelected {
+		repositories = nil
+	}
+
+	token := s.tokens.IssueWithExpire(&Token{
+		Kind:                TokenKindInstallation,
+		ClientID:            app.ClientID,
+		InstallationID:      installation.ID,
+		Permissions:         permissions,
+		RepositorySelection: selection,
+		Repositories:        repositories,
+	}, installationTokenExpire)
+
+	tok, _ := s.tokens.Get(token)
+
+	out := &GitHubInstallationToken{
+		Token:               token,
+		ExpiresAt:           tok.ExpiresAt,
+		Permissions:         permissions,
+		RepositorySelection: selection,
+	}
+
+	for _, repo := range repositories {
+		out.Repositories = append(out.Repositories, GitHubInstallationRepository{
+			Name:     repo,
+			FullName: installation.Account + "/" + repo,
+		})
+	}
+
+	c.JSON(http.StatusCreated, out)
+}
+
+// installationCanRead reports whether the installation token tok may read
+// repo. The repository must be owned by the installation account and within
+// the token's repositories, and the token must hold the metadata or contents
+// permission.
+func (s *Server) installationCanRead(tok *Token, repo *Repo) bool {
+	if !permissionGrants(tok.Permissions["metadata"], "read") &&
+		!permissionGrants(tok.Permissions["contents"], "read") {
+		return false
+	}
+
+	_, installation, ok := s.apps.Installation(tok.InstallationID)
+	if !ok || !strings.EqualFold(repo.Owner, installation.Account) {
+		return false
+	}
+
+	if tok.RepositorySelection != repositorySelectionSelected {
+		return true
+	}
+
+	return slices.ContainsFunc(tok.Repositories, func(v string) bool {
+		return strings.EqualFold(v, repo.Name)
+	})
+}
+
+// apiV3InstallationRepositories lists the repositories the installation
+// access token can read.
+func (s *Server) apiV3InstallationRepositories(c *gin.Context) {
+	tok := contextToken(c)
+	if tok.Kind != TokenKindInstallation {
+		c.AbortWithStatusJSON(http.StatusForbidden, &GitHubAPIError{
+			Message: "You must authenticate with an installation access token in order to list repositories " +
+				"for an installation.",
+			DocumentationURL: apiDocumentationURL,
+		})
+		return
+	}
+
+	_, installation, ok := s.apps.Installation(tok.InstallationID)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, BadCredentialsGitHubAPIError())
+		return
+	}
+
+	out := &GitHubInstallationRepositories{
+		RepositorySelection: repositorySelectionAll,
+		Repositories:        []*GitHubAPIRepository{},
+	}
+
+	if tok.RepositorySelection == repositorySelectionSelected {
+		out.RepositorySelection = repositorySelectionSelected
+	}
+
+	for _, repo := range s.repos.OwnedBy(installation.Account) {
+		if s.installationCanRead(tok, repo) {
+			out.Repositories = append(out.Repositories, s.githubRepository(repo, ""))
+		}
+	}
+
+	out.TotalCount = len(out.Repositories)
+
+	c.JSON(http.StatusOK, out)
+}
+
+// permissionGrants reports whether an installation granted level may mint a
+// token with requested, where admin implies write and write implies read.
+func permissionGrants(granted, requested string) bool {
+	rank := func(level string) int {
+		switch level {
+		case "read":
+			return 1
+		case "write":
+			return 2 //nolint:mnd // write is above read.
+		case "admin":
+			return 3 //nolint:mnd // admin is above write.
+		}
+
+		return 0
+	}
+
+	return rank(requested) > 0 && rank(granted) >= rank(requested)
+}
+
+// accountUser returns the fixture user for login as an API user, or a bare
+// user with just the login when there is no fixture.
+func (s *Server) accountUser(login string) *GitHubAPIUserResponse {
+	if user, ok := s.users.Get(login); ok {
+		return user.WithURLs(s.baseURL, s.apiURL).ForScopes(nil)
+	}
+
+	if login == "" {
+		return nil
+	}
+
+	return (&GitHubAPIUser{Login: login, Type: "User"}).WithURLs(s.baseURL, s.apiURL).ForScopes(nil)
+}
+
+func (s *Server) githubApp(app *App) *GitHubApp {
+	name := app.Name
+	if name == "" {
+		name = app.Slug
+	}
+
+	return &GitHubApp{
+		ID:                 app.ID,
+		Slug:               app.Slug,
+		NodeID:             "A_" + strconv.FormatInt(app.ID, 10),
+		ClientID:           app.ClientID,
+		Owner:              s.accountUser(app.Owner),
+		Name:               name,
+		Description:        app.Description,
+		ExternalURL:        s.baseURL.String(),
+		HTMLURL:            urlMustResolve(s.baseURL, "/apps/"+app.Slug).String(),
+		CreatedAt:          timeMustParseDef("2017-07-08T16:18:44Z"),
+		UpdatedAt:          timeMustParseDef("2017-07-08T16:18:44Z"),
+		Permissions:        nonNilMap(app.Permissions),
+		Events:             nonNilSlice(app.Events),
+		InstallationsCount: len(app.Installations),
+	}
+}
+
+func (s *Server) githubInstallation(app *App, installation *Installation) *GitHubInstallation {
+	id := strconv.FormatInt(installation.ID, 10)
+
+	selection := installation.RepositorySelection
+	if selection == "" {
+		selection = repositorySelectionAll
+	}
+
+	targetType := installation.TargetType
+	if targetType == "" {
+		targetType = "User"
+	}
+
+	out := &GitHubInstallation{
+		ID:                  installation.ID,
+		Account:             s.accountUser(installation.Account),
+		RepositorySelection: selection,
+		AccessTokensURL:     apiMustResolve(s.apiURL, "/app/installations/"+id+"/access_tokens").String(),
+		RepositoriesURL:     apiMustResolve(s.apiURL, "/installation/repositories").String(),
+		HTMLURL:             urlMustResolve(s.baseURL, "/settings/installations/"+id).String(),
+		AppID:               app.ID,
+		AppSlug:             app.Slug,
+		TargetType:          targetType,
+		Permissions:         nonNilMap(installation.Permissions),
+		Events:              nonNilSlice(installation.Events),
+		CreatedAt:           timeMustParseDef("2018-02-09T20:51:14Z"),
+		UpdatedAt:           timeMustParseDef("2018-02-09T20:51:14Z"),
+	}
+
+	if out.Account != nil {
+		out.TargetID = out.Account.ID
+	}
+
+	if installation.Suspended {
+		out.SuspendedAt = &out.UpdatedAt
+	}
+
+	return out
+}
+
+func nonNilMap(v map[string]string) map[string]string {
+	if v == nil {
+		return map[string]string{}
+	}
+
+	return v
+}
+
+func nonNilSlice(v []string) []string {
+	if v == nil {
+		return []string{}
+	}
+
+	return v
+}
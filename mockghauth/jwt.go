@@ -0,0 +1,230 @@
+package mockghauth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	appJWTMaxLifetime = 10 * time.Minute
+	appJWTClockSkew   = 60 * time.Second
+)
+
+var (
+	ErrJWTMalformed       = errors.New("jwt could not be decoded")
+	ErrJWTAlgorithm       = errors.New("jwt algorithm must be RS256")
+	ErrJWTIssuer          = errors.New("jwt issuer is not a known app")
+	ErrJWTIssuedInFuture  = errors.New("jwt issued in the future")
+	ErrJWTExpired         = errors.New("jwt expired")
+	ErrJWTExpiryTooFar    = errors.New("jwt expiry too far in the future")
+	ErrJWTSignature       = errors.New("jwt signature could not be verified")
+	ErrInvalidPEM         = errors.New("no PEM encoded key found")
+	ErrUnsupportedKeyType = errors.New("key is not an RSA key")
+)
+
+// jwtHeader is the JOSE header of a JWT.
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ,omitempty"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// AppJWTClaims are the claims GitHub checks in a GitHub App JWT.
+type AppJWTClaims struct {
+	Issuer    json.RawMessage `json:"iss"`
+	IssuedAt  int64           `json:"iat"`
+	ExpiresAt int64           `json:"exp"`
+}
+
+// issuer returns the iss claim, which GitHub accepts as either the numeric app
+// ID or the app's client ID.
+func (c *AppJWTClaims) issuer() string {
+	var v string
+	if err := json.Unmarshal(c.Issuer, &v); err == nil {
+		return v
+	}
+
+	var n int64
+	if err := json.Unmarshal(c.Issuer, &n); err == nil {
+		return strconv.FormatInt(n, 10)
+	}
+
+	return ""
+}
+
+// NewAppJWT returns an RS256 JWT for the app with ID or client ID issuer,
+// valid from iat until exp, signed with key.
+func NewAppJWT(key *rsa.PrivateKey, issuer string, iat, exp time.Time) (string, error) {
+	iss, err := json.Marshal(issuer)
+	if n, convErr := strconv.ParseInt(issuer, 10, 64); convErr == nil {
+		iss, err = json.Marshal(n)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return signJWT(key, "", &AppJWTClaims{Issuer: iss, IssuedAt: iat.Unix(), ExpiresAt: exp.Unix()})
+}
+
+// signJWT returns claims as an RS256 JWT signed with key, with kid in the
+// header when set.
+func signJWT(key *rsa.PrivateKey, kid string, claims any) (string, error) {
+	header, err := json.Marshal(&jwtHeader{Alg: "RS256", Typ: "JWT", Kid: kid})
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// parseAppJWT decodes token without verifying it, returning the claims, the
+// signing input and the signature.
+func parseAppJWT(token string) (*AppJWTClaims, string, []byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 { //nolint:mnd // header, claims and signature.
+		return nil, "", nil, ErrJWTMalformed
+	}
+
+	var header jwtHeader
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, "", nil, ErrJWTMalformed
+	}
+
+	if header.Alg != "RS256" {
+		return nil, "", nil, ErrJWTAlgorithm
+	}
+
+	var claims AppJWTClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, "", nil, ErrJWTMalformed
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, "", nil, ErrJWTMalformed
+	}
+
+	return &claims, parts[0] + "." + parts[1], sig, nil
+}
+
+func decodeJWTPart(part string, v any) error {
+	buf, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(buf, v)
+}
+
+// checkAppJWTTimes checks the iat and exp claims the way GitHub does: iat may
+// not be in the future, exp must be in the future and no more than ten
+// minutes after now. A minute of clock skew is allowed.
+func checkAppJWTTimes(claims *AppJWTClaims, now time.Time) error {
+	if claims.IssuedAt == 0 || time.Unix(claims.IssuedAt, 0).After(now.Add(appJWTClockSkew)) {
+		return ErrJWTIssuedInFuture
+	}
+
+	exp := time.Unix(claims.ExpiresAt, 0)
+	if claims.ExpiresAt == 0 || !exp.After(now) {
+		return ErrJWTExpired
+	}
+
+	if exp.After(now.Add(appJWTMaxLifetime + appJWTClockSkew)) {
+		return ErrJWTExpiryTooFar
+	}
+
+	return nil
+}
+
+// verifyAppJWTSignature checks the RS256 signature over signingInput.
+func verifyAppJWTSignature(key *rsa.PublicKey, signingInput string, sig []byte) error {
+	digest := sha256.Sum256([]byte(signingInput))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return ErrJWTSignature
+	}
+
+	return nil
+}
+
+// ParseRSAPublicKeyPEM returns the RSA public key in a PEM block, which may be
+// a public key or a private key the public key is taken from.
+func ParseRSAPublicKeyPEM(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidPEM
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY", "PRIVATE KEY":
+		key, err := ParseRSAPrivateKeyPEM(data)
+		if err != nil {
+			return nil, err
+		}
+
+		return &key.PublicKey, nil
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse public key: %w", err)
+	}
+
+	key, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, ErrUnsupportedKeyType
+	}
+
+	return key, nil
+}
+
+// ParseRSAPrivateKeyPEM returns the RSA private key in a PKCS#1 or PKCS#8 PEM
+// block, the format GitHub downloads app keys in.
+func ParseRSAPrivateKeyPEM(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidPEM
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	v, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse private key: %w", err)
+	}
+
+	key, ok := v.(*rsa.PrivateKey)
+	if !ok {
+		return nil, ErrUnsupportedKeyType
+	}
+
+	return key, nil
+}
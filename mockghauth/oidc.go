@@ -0,0 +1,271 @@
+package mockghauth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid/v2"
+)
+
+const (
+	defaultOIDCTokenExpire = 5 * time.Minute
+	oidcKeyBits            = 2048
+
+	defaultOIDCRepository = "octo-org/octo-repo"
+	defaultOIDCRef        = "refs/heads/main"
+	defaultOIDCWorkflow   = "CI"
+)
+
+// oidcClaimsSupported are the claims GitHub Actions lists in its discovery
+// document.
+func oidcClaimsSupported() []string {
+	return []string{
+		"sub", "aud", "exp", "iat", "iss", "jti", "nbf",
+		"ref", "sha", "repository", "repository_id", "repository_owner", "repository_owner_id",
+		"run_id", "run_number", "run_attempt", "actor", "actor_id",
+		"workflow", "workflow_ref", "workflow_sha", "head_ref", "base_ref", "event_name",
+		"ref_type", "ref_protected", "environment", "job_workflow_ref", "job_workflow_sha",
+		"repository_visibility", "runner_environment",
+	}
+}
+
+// OIDCDefaults are the claim values used when a mint request does not set
+// them.
+type OIDCDefaults struct {
+	Repository string
+	Ref        string
+	Workflow   string
+	Actor      string
+	Audience   string
+}
+
+// OIDCIssuer mints GitHub Actions style OIDC ID tokens.
+type OIDCIssuer struct {
+	issuer   string
+	expire   time.Duration
+	defaults OIDCDefaults
+
+	keyOnce sync.Once
+	key     *rsa.PrivateKey
+	kid     string
+	keyErr  error
+}
+
+// NewOIDCIssuer returns an issuer signing with key or, when key is nil, with a
+// key generated the first time one is needed.
+func NewOIDCIssuer(issuer string, key *rsa.PrivateKey, expire time.Duration, defaults OIDCDefaults) *OIDCIssuer {
+	if expire <= 0 {
+		expire = defaultOIDCTokenExpire
+	}
+
+	if defaults.Repository == "" {
+		defaults.Repository = defaultOIDCRepository
+	}
+
+	if defaults.Ref == "" {
+		defaults.Ref = defaultOIDCRef
+	}
+
+	if defaults.Workflow == "" {
+		defaults.Workflow = defaultOIDCWorkflow
+	}
+
+	if defaults.Actor == "" {
+		defaults.Actor = defaultUserLogin
+	}
+
+	return &OIDCIssuer{
+		issuer:   strings.TrimSuffix(issuer, "/"),
+		expire:   expire,
+		defaults: defaults,
+		key:      key,
+	}
+}
+
+// signingKey returns the signing key and its kid, generating the key on first
+// use when none was loaded.
+func (o *OIDCIssuer) signingKey() (*rsa.PrivateKey, string, error) {
+	o.keyOnce.Do(func() {
+		if o.key == nil {
+			if o.key, o.keyErr = rsa.GenerateKey(rand.Reader, oidcKeyBits); o.keyErr != nil {
+				o.keyErr = fmt.Errorf("unable to generate oidc key: %w", o.keyErr)
+				return
+			}
+		}
+
+		o.kid = jwkThumbprint(&o.key.PublicKey)
+	})
+
+	return o.key, o.kid, o.keyErr
+}
+
+// ReadOIDCKeyFile loads the PEM encoded RSA private key ID tokens are signed
+// with.
+func ReadOIDCKeyFile(filename string) (*rsa.PrivateKey, error) {
+	buf, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read oidc-key-file(%s): %w", filename, err)
+	}
+
+	key, err := ParseRSAPrivateKeyPEM(buf)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse oidc-key-file(%s): %w", filename, err)
+	}
+
+	return key, nil
+}
+
+// Issuer returns the iss claim of minted tokens.
+func (o *OIDCIssuer) Issuer() string {
+	return o.issuer
+}
+
+// PublicKey returns the key minted tokens verify with.
+func (o *OIDCIssuer) PublicKey() (*rsa.PublicKey, error) {
+	key, _, err := o.signingKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return &key.PublicKey, nil
+}
+
+// Discovery returns the OpenID Provider configuration document.
+func (o *OIDCIssuer) Discovery() *OIDCConfiguration {
+	return &OIDCConfiguration{
+		Issuer:                           o.issuer,
+		JWKSURI:                          o.issuer + "/.well-known/jwks",
+		SubjectTypesSupported:            []string{"public", "pairwise"},
+		ResponseTypesSupported:           []string{"id_token"},
+		ClaimsSupported:                  oidcClaimsSupported(),
+		IDTokenSigningAlgValuesSupported: []string{"RS256"},
+		ScopesSupported:                  []string{"openid"},
+	}
+}
+
+// JWKS returns the key set minted tokens verify with.
+func (o *OIDCIssuer) JWKS() (*JWKS, error) {
+	key, kid, err := o.signingKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return &JWKS{
+		Keys: []JWK{{
+			Kty: "RSA",
+			Alg: "RS256",
+			Use: "sig",
+			Kid: kid,
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	}, nil
+}
+
+// Claims returns the claims a token is minted with: the defaults, overridden
+// by overrides. The sub claim is derived from the repository and ref unless
+// set, and aud defaults to the repository owner's URL as GitHub does.
+func (o *OIDCIssuer) Claims(overrides map[string]any, now time.Time) map[string]any {
+	repository := o.defaults.Repository
+	if v, ok := overrides["repository"].(string); ok && v != "" {
+		repository = v
+	}
+
+	ref := o.defaults.Ref
+	if v, ok := overrides["ref"].(string); ok && v != "" {
+		ref = v
+	}
+
+	owner, _, _ := strings.Cut(repository, "/")
+
+	workflow := o.defaults.Workflow
+	if v, ok := overrides["workflow"].(string); ok && v != "" {
+		workflow = v
+	}
+
+	audience := o.defaults.Audience
+	if audience == "" {
+		audience = "https://github.com/" + owner
+	}
+
+	refType := "branch"
+	if strings.HasPrefix(ref, "refs/tags/") {
+		refType = "tag"
+	}
+
+	workflowRef := repository + "/.github/workflows/" + strings.ToLower(workflow) + ".yml@" + ref
+	sha := strings.Repeat("0", 40) //nolint:mnd // length of a git SHA-1.
+
+	claims := map[string]any{
+		"jti":                   ulid.Make().String(),
+		"sub":                   "repo:" + repository + ":ref:" + ref,
+		"aud":                   audience,
+		"ref":                   ref,
+		"ref_type":              refType,
+		"ref_protected":         "false",
+		"head_ref":              "",
+		"base_ref":              "",
+		"sha":                   sha,
+		"repository":            repository,
+		"repository_id":         "1",
+		"repository_owner":      owner,
+		"repository_owner_id":   "1",
+		"repository_visibility": "private",
+		"run_id":                "1",
+		"run_number":            "1",
+		"run_attempt":           "1",
+		"actor":                 o.defaults.Actor,
+		"actor_id":              "1",
+		"workflow":              workflow,
+		"workflow_ref":          workflowRef,
+		"workflow_sha":          sha,
+		"job_workflow_ref":      workflowRef,
+		"job_workflow_sha":      sha,
+		"event_name":            "push",
+		"runner_environment":    "github-hosted",
+	}
+
+	for k, v := range overrides {
+		claims[k] = v
+	}
+
+	claims["iss"] = o.issuer
+	claims["iat"] = now.Unix()
+	claims["nbf"] = now.Unix()
+	claims["exp"] = now.Add(o.expire).Unix()
+
+	return claims
+}
+
+// Mint returns a signed ID token with the default claims, overridden by
+// overrides.
+func (o *OIDCIssuer) Mint(overrides map[string]any) (string, error) {
+	key, kid, err := o.signingKey()
+	if err != nil {
+		return "", err
+	}
+
+	return signJWT(key, kid, o.Claims(overrides, time.Now()))
+}
+
+// jwkThumbprint returns the RFC 7638 thumbprint of key, used as its kid.
+func jwkThumbprint(key *rsa.PublicKey) string {
+	buf, _ := json.Marshal(map[string]string{
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		"kty": "RSA",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+	})
+
+	sum := sha256.Sum256(buf)
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
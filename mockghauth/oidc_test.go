@@ -0,0 +1,140 @@
+package mockghauth_test
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dosquad/mock-oauth-test-server/mockghauth"
+)
+
+// verifyIDToken checks the RS256 signature of token with key and returns its
+// header and claims.
+func verifyIDToken(t *testing.T, key *rsa.PublicKey, token string) (map[string]any, map[string]any) {
+	t.Helper()
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("ID token has %d parts, expected = 3", len(parts))
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatalf("signature decode error = %v", err)
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		t.Fatalf("rsa.VerifyPKCS1v15() error = %v", err)
+	}
+
+	decode := func(part string) map[string]any {
+		buf, decodeErr := base64.RawURLEncoding.DecodeString(part)
+		if decodeErr != nil {
+			t.Fatalf("part decode error = %v", decodeErr)
+		}
+
+		out := map[string]any{}
+		if decodeErr = json.Unmarshal(buf, &out); decodeErr != nil {
+			t.Fatalf("part unmarshal error = %v", decodeErr)
+		}
+
+		return out
+	}
+
+	return decode(parts[0]), decode(parts[1])
+}
+
+func TestOIDCIssuer_Claims(t *testing.T) {
+	issuer := mockghauth.NewOIDCIssuer("http://localhost:8080/", nil, 0, mockghauth.OIDCDefaults{})
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		overrides map[string]any
+		want      map[string]any
+	}{
+		{"defaults", nil, map[string]any{
+			"iss":        "http://localhost:8080",
+			"sub":        "repo:octo-org/octo-repo:ref:refs/heads/main",
+			"aud":        "https://github.com/octo-org",
+			"repository": "octo-org/octo-repo",
+			"workflow":   "CI",
+			"ref_type":   "branch",
+			"exp":        now.Add(5 * time.Minute).Unix(),
+		}},
+		{"repository and ref", map[string]any{"repository": "acme/app", "ref": "refs/tags/v1.0.0"}, map[string]any{
+			"sub":              "repo:acme/app:ref:refs/tags/v1.0.0",
+			"aud":              "https://github.com/acme",
+			"repository_owner": "acme",
+			"ref_type":         "tag",
+		}},
+		{"explicit sub and aud", map[string]any{"sub": "repo:acme/app:environment:prod", "aud": "sts.amazonaws.com"},
+			map[string]any{
+				"sub": "repo:acme/app:environment:prod",
+				"aud": "sts.amazonaws.com",
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := issuer.Claims(tt.overrides, now)
+			for k, want := range tt.want {
+				if got[k] != want {
+					t.Errorf("OIDCIssuer.Claims()[%s]: expected = %v, received = %v", k, want, got[k])
+				}
+			}
+		})
+	}
+}
+
+func TestOIDCIssuer_ClaimsSupported(t *testing.T) {
+	issuer := mockghauth.NewOIDCIssuer("http://localhost:8080/", nil, 0, mockghauth.OIDCDefaults{Actor: "octocat"})
+	claims := issuer.Claims(nil, time.Now())
+
+	for _, name := range issuer.Discovery().ClaimsSupported {
+		// environment is only present when the job targets an environment.
+		if name == "environment" {
+			continue
+		}
+
+		if _, ok := claims[name]; !ok {
+			t.Errorf("OIDCIssuer.Claims()[%s]: expected to be emitted as it is in claims_supported", name)
+		}
+	}
+}
+
+func TestOIDCIssuer_Mint(t *testing.T) {
+	issuer := mockghauth.NewOIDCIssuer("http://localhost:8080", loadAppKey(t), time.Minute, mockghauth.OIDCDefaults{
+		Repository: "acme/app",
+	})
+
+	token, err := issuer.Mint(map[string]any{"workflow": "Deploy"})
+	if err != nil {
+		t.Fatalf("OIDCIssuer.Mint() error = %v", err)
+	}
+
+	key, err := issuer.PublicKey()
+	if err != nil {
+		t.Fatalf("OIDCIssuer.PublicKey() error = %v", err)
+	}
+
+	header, claims := verifyIDToken(t, key, token)
+
+	jwks, err := issuer.JWKS()
+	if err != nil {
+		t.Fatalf("OIDCIssuer.JWKS() error = %v", err)
+	}
+
+	if header["alg"] != "RS256" || header["kid"] != jwks.Keys[0].Kid {
+		t.Errorf("ID token header = %v, expected RS256 with kid %q", header, jwks.Keys[0].Kid)
+	}
+
+	if claims["workflow"] != "Deploy" || claims["repository"] != "acme/app" {
+		t.Errorf("ID token claims = %v, expected workflow Deploy in acme/app", claims)
+	}
+}
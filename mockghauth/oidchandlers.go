@@ -0,0 +1,116 @@
+package mockghauth
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) wellKnownOpenIDConfiguration(c *gin.Context) {
+	c.JSON(http.StatusOK, s.oidc.Discovery())
+}
+
+func (s *Server) wellKnownJWKS(c *gin.Context) {
+	jwks, err := s.oidc.JWKS()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, &GitHubAPIError{
+			Message:          err.Error(),
+			DocumentationURL: apiDocumentationURL,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, jwks)
+}
+
+// mockOIDCToken mints an ID token, in the shape the Actions runtime returns
+// from a GET of ACTIONS_ID_TOKEN_REQUEST_URL. Claims are taken from the query
+// string and then from a JSON object body, where audience sets aud as it does
+// for the runtime. The id claims come from the fixtures when not set.
+func (s *Server) mockOIDCToken(c *gin.Context) {
+	overrides := map[string]any{}
+	override := func(k string, v any) {
+		if k == "audience" {
+			k = "aud"
+		}
+
+		overrides[k] = v
+	}
+
+	for k, v := range c.Request.URL.Query() {
+		override(k, v[0])
+	}
+
+	if c.Request.ContentLength != 0 && c.ContentType() == gin.MIMEJSON {
+		body := map[string]any{}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, &GitHubAPIError{
+				Message:          "Problems parsing JSON",
+				DocumentationURL: apiDocumentationURL,
+			})
+			return
+		}
+
+		for k, v := range body {
+			override(k, v)
+		}
+	}
+
+	s.oidcIDClaims(overrides)
+
+	token, err := s.oidc.Mint(overrides)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, &GitHubAPIError{
+			Message:          err.Error(),
+			DocumentationURL: apiDocumentationURL,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"value": token})
+}
+
+// oidcIDClaims sets the repository_id, repository_owner_id and actor_id
+// claims to the IDs of the fixture repository, owner and actor the token is
+// minted for, unless overrides already sets them.
+func (s *Server) oidcIDClaims(overrides map[string]any) {
+	repository := s.oidc.defaults.Repository
+	if v, ok := overrides["repository"].(string); ok && v != "" {
+		repository = v
+	}
+
+	actor := s.oidc.defaults.Actor
+	if v, ok := overrides["actor"].(string); ok && v != "" {
+		actor = v
+	}
+
+	owner, name, _ := strings.Cut(repository, "/")
+
+	ids := map[string]int{}
+	if repo, ok := s.repos.Get(owner, name); ok {
+		ids["repository_id"] = repo.ID
+	}
+
+	if org, ok := s.orgs.Get(owner); ok {
+		ids["repository_owner_id"] = org.ID
+	} else if user, isUser := s.users.Get(owner); isUser {
+		ids["repository_owner_id"] = user.ID
+	}
+
+	if user, ok := s.users.Get(actor); ok {
+		ids["actor_id"] = user.ID
+	}
+
+	for k, v := range ids {
+		if _, ok := overrides[k]; !ok {
+			overrides[k] = strconv.Itoa(v)
+		}
+	}
+}
+
+// OIDC returns the server's OIDC ID token issuer.
+func (s *Server) OIDC() *OIDCIssuer {
+	return s.oidc
+}
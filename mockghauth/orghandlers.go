@@ -0,0 +1,255 @@
+package mockghauth
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultTeamPrivacy    = "closed"
+	defaultTeamPermission = "pull"
+)
+
+// requesterLogin returns the login of the user the request is authenticated
+// as, or "" for anonymous and installation requests.
+func (s *Server) requesterLogin(c *gin.Context) string {
+	if _, ok := c.Get(tokenContextKey); !ok || contextToken(c).Kind == TokenKindInstallation {
+		return ""
+	}
+
+	user, ok := s.contextUser(c)
+	if !ok {
+		return ""
+	}
+
+	return user.Login
+}
+
+// contextOrg returns the organization in the path.
+func (s *Server) contextOrg(c *gin.Context) (*Org, bool) {
+	org, ok := s.orgs.Get(c.Param("org"))
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusNotFound, NotFoundGitHubAPIError())
+		return nil, false
+	}
+
+	return org, true
+}
+
+func (s *Server) apiV3UserOrgs(c *gin.Context) {
+	user, ok := s.contextAuthenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	orgs := s.orgs.ForUser(user.Login)
+
+	out := make([]*GitHubAPIOrganization, 0, len(orgs))
+	for _, v := range orgs {
+		out = append(out, s.githubOrganization(v))
+	}
+
+	c.JSON(http.StatusOK, out)
+}
+
+// apiV3UserTeams lists the teams, across every organization, the user is an
+// active member of.
+func (s *Server) apiV3UserTeams(c *gin.Context) {
+	user, ok := s.contextAuthenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	out := []*GitHubAPITeam{}
+	for _, org := range s.orgs.ForUser(user.Login) {
+		for _, team := range org.Teams {
+			if m, isMember := team.Member(user.Login); isMember && m.Active() {
+				out = append(out, s.githubTeam(org, team))
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, out)
+}
+
+// apiV3OrgMember checks membership the way GitHub does: members of the
+// organization, with a read:org token, get 204 or 404, everyone else is
+// redirected to the public membership check.
+func (s *Server) apiV3OrgMember(c *gin.Context) {
+	org, ok := s.contextOrg(c)
+	if !ok {
+		return
+	}
+
+	requester := s.requesterLogin(c)
+	if requester == "" || !contextToken(c).Scopes.Has("read:org") || !org.IsActiveMember(requester) {
+		c.Redirect(http.StatusFound, s.orgURL(org, "/public_members/"+url.PathEscape(c.Param("username"))))
+		return
+	}
+
+	if !org.IsActiveMember(c.Param("username")) {
+		c.AbortWithStatusJSON(http.StatusNotFound, NotFoundGitHubAPIError())
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// apiV3OrgPublicMember checks the user has publicized their membership.
+func (s *Server) apiV3OrgPublicMember(c *gin.Context) {
+	org, ok := s.contextOrg(c)
+	if !ok {
+		return
+	}
+
+	if m, isMember := org.Member(c.Param("username")); !isMember || !m.Active() || !m.Public {
+		c.AbortWithStatusJSON(http.StatusNotFound, NotFoundGitHubAPIError())
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// apiV3OrgMembership returns a user's membership, including pending
+// invitations. Only members may see other users' memberships.
+func (s *Server) apiV3OrgMembership(c *gin.Context) {
+	org, ok := s.contextOrg(c)
+	if !ok {
+		return
+	}
+
+	username := c.Param("username")
+	requester := s.requesterLogin(c)
+
+	if !strings.EqualFold(requester, username) && !org.IsActiveMember(requester) {
+		c.AbortWithStatusJSON(http.StatusForbidden, &GitHubAPIError{
+			Message: "You must be a member of " + org.Login + " to see membership information for " +
+				username + ".",
+			DocumentationURL: apiDocumentationURL,
+		})
+		return
+	}
+
+	m, isMember := org.Member(username)
+	if !isMember {
+		c.AbortWithStatusJSON(http.StatusNotFound, NotFoundGitHubAPIError())
+		return
+	}
+
+	c.JSON(http.StatusOK, &GitHubAPIOrgMembership{
+		URL:             s.orgURL(org, "/memberships/"+url.PathEscape(m.Login)),
+		State:           membershipState(m.State),
+		Role:            membershipRole(m.Role),
+		OrganizationURL: s.orgURL(org, ""),
+		Organization:    s.githubOrganization(org),
+		User:            s.accountUser(m.Login),
+	})
+}
+
+// apiV3OrgTeamMembership returns a user's team membership. Teams are hidden
+// from users outside the organization.
+func (s *Server) apiV3OrgTeamMembership(c *gin.Context) {
+	org, ok := s.contextOrg(c)
+	if !ok {
+		return
+	}
+
+	team, teamExists := org.Team(c.Param("team_slug"))
+	if !teamExists || !org.IsActiveMember(s.requesterLogin(c)) {
+		c.AbortWithStatusJSON(http.StatusNotFound, NotFoundGitHubAPIError())
+		return
+	}
+
+	m, isMember := team.Member(c.Param("username"))
+	if !isMember {
+		c.AbortWithStatusJSON(http.StatusNotFound, NotFoundGitHubAPIError())
+		return
+	}
+
+	c.JSON(http.StatusOK, &GitHubAPITeamMembership{
+		URL:   s.orgURL(org, "/teams/"+url.PathEscape(team.Slug)+"/memberships/"+url.PathEscape(m.Login)),
+		Role:  membershipRole(m.Role),
+		State: membershipState(m.State),
+	})
+}
+
+// orgURL returns the API URL of the organization with suffix appended.
+func (s *Server) orgURL(org *Org, suffix string) string {
+	return apiMustResolve(s.apiURL, "/orgs/"+url.PathEscape(org.Login)+suffix).String()
+}
+
+func (s *Server) githubOrganization(org *Org) *GitHubAPIOrganization {
+	nodeID := org.NodeID
+	if nodeID == "" {
+		nodeID = "O_" + strconv.Itoa(org.ID)
+	}
+
+	return &GitHubAPIOrganization{
+		Login:            org.Login,
+		ID:               org.ID,
+		NodeID:           nodeID,
+		URL:              s.orgURL(org, ""),
+		ReposURL:         s.orgURL(org, "/repos"),
+		EventsURL:        s.orgURL(org, "/events"),
+		HooksURL:         s.orgURL(org, "/hooks"),
+		IssuesURL:        s.orgURL(org, "/issues"),
+		MembersURL:       s.orgURL(org, "/members{/member}"),
+		PublicMembersURL: s.orgURL(org, "/public_members{/member}"),
+		AvatarURL:        urlMustResolve(s.baseURL, "/images/error/octocat_happy.gif").String(),
+		Description:      org.Description,
+	}
+}
+
+func (s *Server) githubTeam(org *Org, team *Team) *GitHubAPITeam {
+	name := team.Name
+	if name == "" {
+		name = team.Slug
+	}
+
+	privacy := team.Privacy
+	if privacy == "" {
+		privacy = defaultTeamPrivacy
+	}
+
+	permission := team.Permission
+	if permission == "" {
+		permission = defaultTeamPermission
+	}
+
+	slug := url.PathEscape(team.Slug)
+
+	return &GitHubAPITeam{
+		ID:              team.ID,
+		NodeID:          "T_" + strconv.Itoa(team.ID),
+		URL:             s.orgURL(org, "/teams/"+slug),
+		HTMLURL:         urlMustResolve(s.baseURL, "/orgs/"+url.PathEscape(org.Login)+"/teams/"+slug).String(),
+		Name:            name,
+		Slug:            team.Slug,
+		Description:     team.Description,
+		Privacy:         privacy,
+		Permission:      permission,
+		MembersURL:      s.orgURL(org, "/teams/"+slug+"/members{/member}"),
+		RepositoriesURL: s.orgURL(org, "/teams/"+slug+"/repos"),
+		Organization:    s.githubOrganization(org),
+	}
+}
+
+func membershipState(state string) string {
+	if state == "" {
+		return membershipStateActive
+	}
+
+	return state
+}
+
+func membershipRole(role string) string {
+	if role == "" {
+		return membershipRoleMember
+	}
+
+	return role
+}
@@ -0,0 +1,215 @@
+package mockghauth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	membershipStateActive = "active"
+	membershipRoleMember  = "member"
+	membershipRoleAdmin   = "admin"
+)
+
+// OrgMember is a user's membership of a fixture organization. Role is admin
+// or member, State active or pending, and Public marks a publicized
+// membership.
+type OrgMember struct {
+	Login  string `json:"login"`
+	Role   string `json:"role,omitempty"`
+	State  string `json:"state,omitempty"`
+	Public bool   `json:"public,omitempty"`
+}
+
+// Active reports whether the membership has been accepted.
+func (m *OrgMember) Active() bool {
+	return m.State == "" || m.State == membershipStateActive
+}
+
+// TeamMember is a user's membership of a fixture team. Role is member or
+// maintainer and State active or pending.
+type TeamMember struct {
+	Login string `json:"login"`
+	Role  string `json:"role,omitempty"`
+	State string `json:"state,omitempty"`
+}
+
+// Active reports whether the membership has been accepted.
+func (m *TeamMember) Active() bool {
+	return m.State == "" || m.State == membershipStateActive
+}
+
+// Team is a fixture team of an organization.
+type Team struct {
+	ID          int           `json:"id"`
+	Slug        string        `json:"slug"`
+	Name        string        `json:"name,omitempty"`
+	Description string        `json:"description,omitempty"`
+	Privacy     string        `json:"privacy,omitempty"`
+	Permission  string        `json:"permission,omitempty"`
+	Members     []*TeamMember `json:"members,omitempty"`
+}
+
+// Member returns the team membership of login.
+func (t *Team) Member(login string) (*TeamMember, bool) {
+	for _, v := range t.Members {
+		if strings.EqualFold(v.Login, login) {
+			return v, true
+		}
+	}
+
+	return nil, false
+}
+
+// Org is a fixture organization with its members and teams.
+type Org struct {
+	Login       string       `json:"login"`
+	ID          int          `json:"id"`
+	NodeID      string       `json:"node_id,omitempty"`
+	Name        string       `json:"name,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Members     []*OrgMember `json:"members,omitempty"`
+	Teams       []*Team      `json:"teams,omitempty"`
+}
+
+// Member returns the organization membership of login.
+func (o *Org) Member(login string) (*OrgMember, bool) {
+	for _, v := range o.Members {
+		if strings.EqualFold(v.Login, login) {
+			return v, true
+		}
+	}
+
+	return nil, false
+}
+
+// IsActiveMember reports whether login is an active member of the
+// organization.
+func (o *Org) IsActiveMember(login string) bool {
+	m, ok := o.Member(login)
+
+	return ok && m.Active()
+}
+
+// Team returns the organization's team with slug.
+func (o *Org) Team(slug string) (*Team, bool) {
+	for _, v := range o.Teams {
+		if strings.EqualFold(v.Slug, slug) {
+			return v, true
+		}
+	}
+
+	return nil, false
+}
+
+type Orgs struct {
+	lock sync.RWMutex
+	orgs map[string]*Org
+}
+
+func NewOrgs() *Orgs {
+	return &Orgs{
+		orgs: make(map[string]*Org),
+	}
+}
+
+// ReadFile loads organizations from a JSON object keyed by login. Entries
+// without a login take it from their key.
+func (o *Orgs) ReadFile(filename string) error {
+	if filename != "" {
+		buf, fileErr := os.ReadFile(filename)
+		if fileErr != nil {
+			return fmt.Errorf("unable to read orgs-file(%s): %w", filename, fileErr)
+		}
+
+		orgs := map[string]*Org{}
+		if err := json.NewDecoder(bytes.NewReader(buf)).Decode(&orgs); err != nil {
+			return fmt.Errorf("unable to parse orgs-file(%s): %w", filename, err)
+		}
+
+		for login, org := range orgs {
+			if org.Login == "" {
+				org.Login = login
+			}
+
+			o.Add(org)
+		}
+	}
+
+	return nil
+}
+
+func (o *Orgs) WriteFile(filename string) error {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	buf := bytes.NewBuffer(nil)
+	if err := json.NewEncoder(buf).Encode(o.orgs); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, buf.Bytes(), 0o600)
+}
+
+// Add adds or replaces an organization, logins are case-insensitive.
+func (o *Orgs) Add(org *Org) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.orgs[strings.ToLower(org.Login)] = org
+}
+
+func (o *Orgs) Get(login string) (*Org, bool) {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	v, ok := o.orgs[strings.ToLower(login)]
+	return v, ok
+}
+
+// List returns every organization, sorted by login.
+func (o *Orgs) List() []*Org {
+	o.lock.RLock()
+	defer o.lock.RUnlock()
+
+	out := make([]*Org, 0, len(o.orgs))
+	for _, v := range o.orgs {
+		out = append(out, v)
+	}
+
+	slices.SortFunc(out, func(a, b *Org) int {
+		return strings.Compare(strings.ToLower(a.Login), strings.ToLower(b.Login))
+	})
+
+	return out
+}
+
+// ForUser returns the organizations login is an active member of, sorted by
+// login.
+func (o *Orgs) ForUser(login string) []*Org {
+	out := []*Org{}
+	for _, v := range o.List() {
+		if v.IsActiveMember(login) {
+			out = append(out, v)
+		}
+	}
+
+	return out
+}
+
+// PublicForUser returns the organizations login has publicized their active
+// membership of, sorted by login.
+func (o *Orgs) PublicForUser(login string) []*Org {
+	out := []*Org{}
+	for _, v := range o.List() {
+		if m, ok := v.Member(login); ok && m.Active() && m.Public {
+			out = append(out, v)
+		}
+	}
+
+	return out
+}
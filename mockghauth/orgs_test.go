@@ -0,0 +1,78 @@
+package mockghauth_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/dosquad/mock-oauth-test-server/mockghauth"
+)
+
+func TestOrgs_ReadFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		wantErr  bool
+	}{
+		{
+			name:     "Reading Test Data",
+			filename: "../testdata/orgs.json",
+		},
+		{
+			name:     "Missing File",
+			filename: "../testdata/missing-orgs.json",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := mockghauth.NewOrgs()
+
+			if err := o.ReadFile(tt.filename); (err != nil) != tt.wantErr {
+				t.Errorf("Orgs.ReadFile() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			org, ok := o.Get("GitHub")
+			if !ok {
+				t.Fatalf("Orgs.Get() login = GitHub, expected to exist")
+			}
+
+			if org.Login != "github" || len(org.Members) != 3 || len(org.Teams) != 2 {
+				t.Errorf("Orgs.Get() login = GitHub, received = %+v", org)
+			}
+		})
+	}
+}
+
+func TestOrgs_ForUser(t *testing.T) {
+	o := mockghauth.NewOrgs()
+	if err := o.ReadFile("../testdata/orgs.json"); err != nil {
+		t.Fatalf("Orgs.ReadFile() error = %v", err)
+	}
+
+	tests := []struct {
+		login string
+		want  []string
+	}{
+		{"octocat", []string{"github", "octo-org"}},
+		{"HUBOT", []string{"github"}},
+		{"outsider", []string{}},
+		{"nobody", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.login, func(t *testing.T) {
+			got := []string{}
+			for _, v := range o.ForUser(tt.login) {
+				got = append(got, v.Login)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Orgs.ForUser() = %v, expected = %v", got, tt.want)
+			}
+		})
+	}
+}
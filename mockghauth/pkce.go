@@ -0,0 +1,64 @@
+package mockghauth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+)
+
+// PKCE code challenge methods supported by the authorize endpoint.
+const (
+	PKCEMethodPlain = "plain"
+	PKCEMethodS256  = "S256"
+)
+
+const (
+	pkceVerifierMinLength = 43
+	pkceVerifierMaxLength = 128
+)
+
+// ValidPKCEMethod reports whether method is a supported code_challenge_method.
+func ValidPKCEMethod(method string) bool {
+	return method == PKCEMethodPlain || method == PKCEMethodS256
+}
+
+// PKCEChallenge returns the code_challenge for verifier using method.
+func PKCEChallenge(verifier, method string) string {
+	if method == PKCEMethodS256 {
+		sum := sha256.Sum256([]byte(verifier))
+		return base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+
+	return verifier
+}
+
+// VerifyPKCE reports whether verifier is well formed (RFC 7636 section 4.1)
+// and matches challenge using method.
+func VerifyPKCE(challenge, method, verifier string) bool {
+	if len(verifier) < pkceVerifierMinLength || len(verifier) > pkceVerifierMaxLength {
+		return false
+	}
+
+	for _, r := range verifier {
+		if !isPKCEUnreserved(r) {
+			return false
+		}
+	}
+
+	if !ValidPKCEMethod(method) {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(PKCEChallenge(verifier, method)), []byte(challenge)) == 1
+}
+
+func isPKCEUnreserved(r rune) bool {
+	switch {
+	case r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+		return true
+	case r == '-', r == '.', r == '_', r == '~':
+		return true
+	}
+
+	return false
+}
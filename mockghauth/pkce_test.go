@@ -0,0 +1,44 @@
+package mockghauth_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dosquad/mock-oauth-test-server/mockghauth"
+)
+
+func TestVerifyPKCE(t *testing.T) {
+	// Test vector from RFC 7636 appendix B.
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	s256 := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	invalid := strings.Repeat("a", 42) + "+"
+
+	tests := []struct {
+		name      string
+		challenge string
+		method    string
+		verifier  string
+		want      bool
+	}{
+		{"S256 match", s256, mockghauth.PKCEMethodS256, verifier, true},
+		{"S256 mismatch", s256, mockghauth.PKCEMethodS256, strings.Repeat("a", 43), false},
+		{"plain match", verifier, mockghauth.PKCEMethodPlain, verifier, true},
+		{"plain mismatch", verifier, mockghauth.PKCEMethodPlain, strings.Repeat("a", 43), false},
+		{"unknown method", verifier, "S512", verifier, false},
+		{"verifier too short", "abc", mockghauth.PKCEMethodPlain, "abc", false},
+		{"verifier too long", strings.Repeat("a", 129), mockghauth.PKCEMethodPlain, strings.Repeat("a", 129), false},
+		{"verifier invalid characters", invalid, mockghauth.PKCEMethodPlain, invalid, false},
+		{"missing verifier", s256, mockghauth.PKCEMethodS256, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mockghauth.VerifyPKCE(tt.challenge, tt.method, tt.verifier); got != tt.want {
+				t.Errorf("VerifyPKCE() = %t, expected = %t", got, tt.want)
+			}
+		})
+	}
+
+	if got := mockghauth.PKCEChallenge(verifier, mockghauth.PKCEMethodS256); got != s256 {
+		t.Errorf("PKCEChallenge() = %q, expected = %q", got, s256)
+	}
+}
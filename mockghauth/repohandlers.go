@@ -0,0 +1,240 @@
+package mockghauth
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// repoRole returns the role login holds on repo through ownership,
+// organization membership or as a collaborator: admin for the owner and the
+// owning organization's admins, read for its other members and otherwise the
+// collaborator role, whichever is highest. It is none without any of these.
+func (s *Server) repoRole(repo *Repo, login string) string {
+	if login == "" {
+		return repoPermissionNone
+	}
+
+	if strings.EqualFold(repo.Owner, login) {
+		return repoPermissionAdmin
+	}
+
+	role := repoPermissionNone
+
+	if org, ok := s.orgs.Get(repo.Owner); ok {
+		if m, isMember := org.Member(login); isMember && m.Active() {
+			role = repoPermissionRead
+			if m.Role == membershipRoleAdmin {
+				return repoPermissionAdmin
+			}
+		}
+	}
+
+	for k, v := range repo.Collaborators {
+		if strings.EqualFold(k, login) && repoPermissionRank(v) > repoPermissionRank(role) {
+			role = v
+		}
+	}
+
+	return role
+}
+
+// repoPermission returns the role login has on repo, which is at least read
+// on public repositories.
+func (s *Server) repoPermission(repo *Repo, login string) string {
+	role := s.repoRole(repo, login)
+	if role == repoPermissionNone && !repo.Private() {
+		return repoPermissionRead
+	}
+
+	return role
+}
+
+// repoVisible reports whether the request may see repo: public repositories
+// are visible to everyone, private ones only to users with access whose token
+// holds the repo scope, or to installation tokens that may read them.
+func (s *Server) repoVisible(c *gin.Context, repo *Repo) bool {
+	if !repo.Private() {
+		return true
+	}
+
+	tok := contextToken(c)
+	if tok.Kind == TokenKindInstallation {
+		return s.installationCanRead(tok, repo)
+	}
+
+	return tok.Scopes.Has("repo") && s.repoRole(repo, s.requesterLogin(c)) != repoPermissionNone
+}
+
+// contextRepo returns the repository in the path when the request may see
+// it.
+func (s *Server) contextRepo(c *gin.Context) (*Repo, bool) {
+	repo, ok := s.repos.Get(c.Param("owner"), c.Param("repo"))
+	if !ok || !s.repoVisible(c, repo) {
+		c.AbortWithStatusJSON(http.StatusNotFound, NotFoundGitHubAPIError())
+		return nil, false
+	}
+
+	return repo, true
+}
+
+// apiV3UserRepos lists the repositories the user owns, collaborates on or
+// can access through an organization. Private repositories need the repo
+// scope. The visibility query parameter narrows the list to public or
+// private repositories.
+func (s *Server) apiV3UserRepos(c *gin.Context) {
+	user, ok := s.contextAuthenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	visibility := c.DefaultQuery("visibility", "all")
+
+	out := []*GitHubAPIRepository{}
+	for _, repo := range s.repos.List() {
+		if s.repoRole(repo, user.Login) == repoPermissionNone || !s.repoVisible(c, repo) {
+			continue
+		}
+
+		if (visibility == repoVisibilityPublic && repo.Private()) ||
+			(visibility == repoVisibilityPrivate && !repo.Private()) {
+			continue
+		}
+
+		out = append(out, s.githubRepository(repo, user.Login))
+	}
+
+	c.JSON(http.StatusOK, out)
+}
+
+func (s *Server) apiV3Repo(c *gin.Context) {
+	repo, ok := s.contextRepo(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, s.githubRepository(repo, s.requesterLogin(c)))
+}
+
+// apiV3RepoCollaboratorPermission returns a user's permission on the
+// repository. Only users with push access, or installation tokens that may
+// read the repository, may check it.
+func (s *Server) apiV3RepoCollaboratorPermission(c *gin.Context) {
+	repo, ok := s.contextRepo(c)
+	if !ok {
+		return
+	}
+
+	if tok := contextToken(c); tok.Kind == TokenKindInstallation {
+		if !s.installationCanRead(tok, repo) {
+			c.AbortWithStatusJSON(http.StatusForbidden, &GitHubAPIError{
+				Message:          "Resource not accessible by integration",
+				DocumentationURL: apiDocumentationURL,
+			})
+			return
+		}
+	} else if repoPermissionRank(s.repoRole(repo, s.requesterLogin(c))) < repoPermissionRank(repoPermissionWrite) {
+		c.AbortWithStatusJSON(http.StatusForbidden, &GitHubAPIError{
+			Message:          "Must have push access to view repository collaborators.",
+			DocumentationURL: apiDocumentationURL,
+		})
+		return
+	}
+
+	user, userExists := s.users.Get(c.Param("username"))
+	if !userExists {
+		c.AbortWithStatusJSON(http.StatusNotFound, &GitHubAPIError{
+			Message:          c.Param("username") + " is not a user",
+			DocumentationURL: apiDocumentationURL,
+		})
+		return
+	}
+
+	role := s.repoPermission(repo, user.Login)
+
+	c.JSON(http.StatusOK, &GitHubAPIRepoCollaboratorPermission{
+		Permission: legacyRepoPermission(role),
+		RoleName:   role,
+		User:       s.apiUser(user, nil),
+	})
+}
+
+// legacyRepoPermission maps a role to the admin, write, read or none level
+// the permission field reports.
+func legacyRepoPermission(role string) string {
+	switch role {
+	case repoPermissionMaintain:
+		return repoPermissionWrite
+	case repoPermissionTriage:
+		return repoPermissionRead
+	}
+
+	return role
+}
+
+// repoOwner returns the owner of repo as an API user, an organization when
+// the owner is a fixture organization.
+func (s *Server) repoOwner(repo *Repo) *GitHubAPIUserResponse {
+	if org, ok := s.orgs.Get(repo.Owner); ok {
+		return (&GitHubAPIUser{
+			Login:  org.Login,
+			ID:     org.ID,
+			NodeID: s.githubOrganization(org).NodeID,
+			Type:   "Organization",
+		}).WithURLs(s.baseURL, s.apiURL).ForScopes(nil)
+	}
+
+	return s.accountUser(repo.Owner)
+}
+
+// githubRepository returns repo as the API describes it to login, including
+// their permissions when the request is authenticated.
+func (s *Server) githubRepository(repo *Repo, login string) *GitHubAPIRepository {
+	fullName := url.PathEscape(repo.Owner) + "/" + url.PathEscape(repo.Name)
+
+	visibility := repo.Visibility
+	if visibility == "" {
+		visibility = repoVisibilityPublic
+	}
+
+	branch := repo.DefaultBranch
+	if branch == "" {
+		branch = defaultBranch
+	}
+
+	out := &GitHubAPIRepository{
+		ID:               repo.ID,
+		NodeID:           "R_" + strconv.Itoa(repo.ID),
+		Name:             repo.Name,
+		FullName:         repo.FullName(),
+		Owner:            s.repoOwner(repo),
+		Private:          repo.Private(),
+		HTMLURL:          urlMustResolve(s.baseURL, "/"+fullName).String(),
+		Description:      repo.Description,
+		Fork:             repo.Fork,
+		URL:              apiMustResolve(s.apiURL, "/repos/"+fullName).String(),
+		CloneURL:         urlMustResolve(s.baseURL, "/"+fullName+".git").String(),
+		CollaboratorsURL: apiMustResolve(s.apiURL, "/repos/"+fullName+"/collaborators{/collaborator}").String(),
+		Visibility:       visibility,
+		DefaultBranch:    branch,
+		CreatedAt:        timeMustParseDef("2011-01-26T19:01:12Z"),
+		UpdatedAt:        timeMustParseDef("2011-01-26T19:14:43Z"),
+		PushedAt:         timeMustParseDef("2011-01-26T19:06:43Z"),
+	}
+
+	if login != "" {
+		rank := repoPermissionRank(s.repoPermission(repo, login))
+		out.Permissions = &GitHubAPIRepoPermissions{
+			Admin:    rank >= repoPermissionRank(repoPermissionAdmin),
+			Maintain: rank >= repoPermissionRank(repoPermissionMaintain),
+			Push:     rank >= repoPermissionRank(repoPermissionWrite),
+			Triage:   rank >= repoPermissionRank(repoPermissionTriage),
+			Pull:     rank >= repoPermissionRank(repoPermissionRead),
+		}
+	}
+
+	return out
+}
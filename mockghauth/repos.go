@@ -0,0 +1,168 @@
+package mockghauth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	repoVisibilityPublic  = "public"
+	repoVisibilityPrivate = "private"
+
+	repoPermissionNone     = "none"
+	repoPermissionRead     = "read"
+	repoPermissionTriage   = "triage"
+	repoPermissionWrite    = "write"
+	repoPermissionMaintain = "maintain"
+	repoPermissionAdmin    = "admin"
+
+	defaultBranch = "main"
+)
+
+// repoPermissionRank orders repository roles, read being the lowest access
+// and admin the highest.
+func repoPermissionRank(permission string) int {
+	switch permission {
+	case repoPermissionRead:
+		return 1
+	case repoPermissionTriage:
+		return 2 //nolint:mnd // triage is above read.
+	case repoPermissionWrite:
+		return 3 //nolint:mnd // write is above triage.
+	case repoPermissionMaintain:
+		return 4 //nolint:mnd // maintain is above write.
+	case repoPermissionAdmin:
+		return 5 //nolint:mnd // admin is above maintain.
+	}
+
+	return 0
+}
+
+// Repo is a fixture repository. Visibility is public, private or internal
+// and Collaborators maps a login to its role: read, triage, write, maintain
+// or admin.
+type Repo struct {
+	ID            int               `json:"id"`
+	Owner         string            `json:"owner"`
+	Name          string            `json:"name"`
+	Description   string            `json:"description,omitempty"`
+	Visibility    string            `json:"visibility,omitempty"`
+	DefaultBranch string            `json:"default_branch,omitempty"`
+	Fork          bool              `json:"fork,omitempty"`
+	Collaborators map[string]string `json:"collaborators,omitempty"`
+}
+
+// FullName returns the owner/name of the repository.
+func (r *Repo) FullName() string {
+	return r.Owner + "/" + r.Name
+}
+
+// Private reports whether the repository is hidden from the public, which
+// internal repositories are too.
+func (r *Repo) Private() bool {
+	return r.Visibility != "" && r.Visibility != repoVisibilityPublic
+}
+
+type Repos struct {
+	lock  sync.RWMutex
+	repos map[string]*Repo
+}
+
+func NewRepos() *Repos {
+	return &Repos{
+		repos: make(map[string]*Repo),
+	}
+}
+
+// ReadFile loads repositories from a JSON object keyed by owner/name. Entries
+// without an owner or name take them from their key.
+func (r *Repos) ReadFile(filename string) error {
+	if filename != "" {
+		buf, fileErr := os.ReadFile(filename)
+		if fileErr != nil {
+			return fmt.Errorf("unable to read repos-file(%s): %w", filename, fileErr)
+		}
+
+		repos := map[string]*Repo{}
+		if err := json.NewDecoder(bytes.NewReader(buf)).Decode(&repos); err != nil {
+			return fmt.Errorf("unable to parse repos-file(%s): %w", filename, err)
+		}
+
+		for fullName, repo := range repos {
+			owner, name, _ := strings.Cut(fullName, "/")
+
+			if repo.Owner == "" {
+				repo.Owner = owner
+			}
+
+			if repo.Name == "" {
+				repo.Name = name
+			}
+
+			r.Add(repo)
+		}
+	}
+
+	return nil
+}
+
+func (r *Repos) WriteFile(filename string) error {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	buf := bytes.NewBuffer(nil)
+	if err := json.NewEncoder(buf).Encode(r.repos); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, buf.Bytes(), 0o600)
+}
+
+// Add adds or replaces a repository, names are case-insensitive.
+func (r *Repos) Add(repo *Repo) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.repos[strings.ToLower(repo.FullName())] = repo
+}
+
+func (r *Repos) Get(owner, name string) (*Repo, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	v, ok := r.repos[strings.ToLower(owner+"/"+name)]
+	return v, ok
+}
+
+// List returns every repository, sorted by full name.
+func (r *Repos) List() []*Repo {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	out := make([]*Repo, 0, len(r.repos))
+	for _, v := range r.repos {
+		out = append(out, v)
+	}
+
+	slices.SortFunc(out, func(a, b *Repo) int {
+		return strings.Compare(strings.ToLower(a.FullName()), strings.ToLower(b.FullName()))
+	})
+
+	return out
+}
+
+// OwnedBy returns the repositories owner owns, sorted by full name.
+func (r *Repos) OwnedBy(owner string) []*Repo {
+	out := []*Repo{}
+	for _, v := range r.List() {
+		if strings.EqualFold(v.Owner, owner) {
+			out = append(out, v)
+		}
+	}
+
+	return out
+}
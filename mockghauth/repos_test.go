@@ -0,0 +1,56 @@
+package mockghauth_test
+
+import (
+	"testing"
+
+	"github.com/dosquad/mock-oauth-test-server/mockghauth"
+)
+
+func TestRepos_ReadFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		wantErr  bool
+	}{
+		{
+			name:     "Reading Test Data",
+			filename: "../testdata/repos.json",
+		},
+		{
+			name:     "Missing File",
+			filename: "../testdata/missing-repos.json",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mockghauth.NewRepos()
+
+			if err := r.ReadFile(tt.filename); (err != nil) != tt.wantErr {
+				t.Errorf("Repos.ReadFile() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if got := len(r.List()); got != 4 {
+				t.Errorf("Repos.List() received = %d repositories, expected = 4", got)
+			}
+
+			repo, ok := r.Get("OctoCat", "Secret-Plans")
+			if !ok {
+				t.Fatalf("Repos.Get() repo = OctoCat/Secret-Plans, expected to exist")
+			}
+
+			if repo.FullName() != "octocat/secret-plans" || !repo.Private() {
+				t.Errorf("Repos.Get() repo = OctoCat/Secret-Plans, received = %+v", repo)
+			}
+
+			if got := len(r.OwnedBy("octocat")); got != 2 {
+				t.Errorf("Repos.OwnedBy() received = %d repositories, expected = 2", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package mockghauth
+
+import (
+	"slices"
+	"strings"
+)
+
+// Scopes is a normalised list of OAuth scopes.
+type Scopes []string
+
+// ParseScopes parses a comma or space separated scope list the way GitHub does:
+// unknown scopes are dropped, duplicates removed and scopes implied by another
+// requested scope (e.g. repo:status by repo) are folded into their parent.
+func ParseScopes(raw string) Scopes {
+	fields := strings.FieldsFunc(raw, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '+'
+	})
+
+	out := Scopes{}
+	for _, scope := range fields {
+		if !knownScope(scope) || slices.Contains(out, scope) {
+			continue
+		}
+
+		out = append(out, scope)
+	}
+
+	normalised := Scopes{}
+	for _, scope := range out {
+		implied := false
+		for _, other := range out {
+			if other != scope && scopeImplies(other, scope) {
+				implied = true
+				break
+			}
+		}
+
+		if !implied {
+			normalised = append(normalised, scope)
+		}
+	}
+
+	slices.Sort(normalised)
+
+	return normalised
+}
+
+// String returns the scopes as returned in the token response scope field.
+func (s Scopes) String() string {
+	return strings.Join(s, ",")
+}
+
+// Header returns the scopes as returned in the X-OAuth-Scopes header.
+func (s Scopes) Header() string {
+	return strings.Join(s, ", ")
+}
+
+// Has reports whether scope was granted, either directly or through a parent
+// scope that implies it.
+func (s Scopes) Has(scope string) bool {
+	for _, granted := range s {
+		if granted == scope || scopeImplies(granted, scope) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasAny reports whether any of scopes was granted.
+func (s Scopes) HasAny(scopes ...string) bool {
+	return slices.ContainsFunc(scopes, s.Has)
+}
+
+// scopeImplies reports whether parent grants child, following the scope
+// hierarchy transitively.
+func scopeImplies(parent, child string) bool {
+	for _, v := range childScopes(parent) {
+		if v == child || scopeImplies(v, child) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func knownScope(scope string) bool {
+	switch scope {
+	case "repo", "repo:status", "repo_deployment", "public_repo", "repo:invite", "security_events",
+		"admin:repo_hook", "write:repo_hook", "read:repo_hook",
+		"admin:org", "write:org", "read:org",
+		"admin:public_key", "write:public_key", "read:public_key",
+		"admin:org_hook", "gist", "notifications",
+		"user", "read:user", "user:email", "user:follow",
+		"project", "read:project", "delete_repo",
+		"write:packages", "read:packages", "delete:packages",
+		"admin:gpg_key", "write:gpg_key", "read:gpg_key",
+		"codespace", "workflow",
+		"admin:enterprise", "manage_runners:enterprise", "manage_billing:enterprise", "read:enterprise",
+		"audit_log", "read:audit_log",
+		"admin:ssh_signing_key", "write:ssh_signing_key", "read:ssh_signing_key":
+		return true
+	}
+
+	return false
+}
+
+// childScopes returns the scopes directly granted by scope.
+func childScopes(scope string) []string {
+	switch scope {
+	case "repo":
+		return []string{"repo:status", "repo_deployment", "public_repo", "repo:invite", "security_events"}
+	case "admin:repo_hook":
+		return []string{"write:repo_hook"}
+	case "write:repo_hook":
+		return []string{"read:repo_hook"}
+	case "admin:org":
+		return []string{"write:org"}
+	case "write:org":
+		return []string{"read:org"}
+	case "admin:public_key":
+		return []string{"write:public_key"}
+	case "write:public_key":
+		return []string{"read:public_key"}
+	case "user":
+		return []string{"read:user", "user:email", "user:follow"}
+	case "project":
+		return []string{"read:project"}
+	case "write:packages":
+		return []string{"read:packages"}
+	case "admin:gpg_key":
+		return []string{"write:gpg_key"}
+	case "write:gpg_key":
+		return []string{"read:gpg_key"}
+	case "admin:enterprise":
+		return []string{"manage_runners:enterprise", "manage_billing:enterprise", "read:enterprise"}
+	case "manage_billing:enterprise":
+		return []string{"read:enterprise"}
+	case "audit_log":
+		return []string{"read:audit_log"}
+	case "admin:ssh_signing_key":
+		return []string{"write:ssh_signing_key"}
+	case "write:ssh_signing_key":
+		return []string{"read:ssh_signing_key"}
+	}
+
+	return nil
+}
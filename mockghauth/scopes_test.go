@@ -0,0 +1,62 @@
+package mockghauth_test
+
+import (
+	"testing"
+
+	"github.com/dosquad/mock-oauth-test-server/mockghauth"
+)
+
+func TestParseScopes(t *testing.T) {
+	tests := []struct {
+		name   string
+		raw    string
+		string string
+		header string
+	}{
+		{"empty", "", "", ""},
+		{"single", "repo", "repo", "repo"},
+		{"comma separated", "user,gist", "gist,user", "gist, user"},
+		{"space separated", "user gist", "gist,user", "gist, user"},
+		{"implied scope folded", "repo,repo:status", "repo", "repo"},
+		{"transitively implied scope folded", "admin:org read:org", "admin:org", "admin:org"},
+		{"duplicates removed", "gist,gist", "gist", "gist"},
+		{"unknown dropped", "gist,not-a-scope", "gist", "gist"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mockghauth.ParseScopes(tt.raw)
+
+			if v := got.String(); v != tt.string {
+				t.Errorf("ParseScopes().String() = %q, expected = %q", v, tt.string)
+			}
+
+			if v := got.Header(); v != tt.header {
+				t.Errorf("ParseScopes().Header() = %q, expected = %q", v, tt.header)
+			}
+		})
+	}
+}
+
+func TestScopes_Has(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes string
+		scope  string
+		want   bool
+	}{
+		{"direct", "gist", "gist", true},
+		{"implied", "repo", "repo:status", true},
+		{"transitively implied", "admin:org", "read:org", true},
+		{"user implies user:email", "user", "user:email", true},
+		{"child does not imply parent", "read:org", "admin:org", false},
+		{"unrelated", "gist", "repo", false},
+		{"none granted", "", "repo", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mockghauth.ParseScopes(tt.scopes).Has(tt.scope); got != tt.want {
+				t.Errorf("Scopes.Has(%q) = %t, expected = %t", tt.scope, got, tt.want)
+			}
+		})
+	}
+}
@@ -2,44 +2,130 @@ package mockghauth
 
 import (
 	"context"
+	"crypto/rsa"
+	"errors"
 	"fmt"
+	"html/template"
 	"net/http"
 	"net/url"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/dosquad/mock-oauth-test-server/internal/staticsrc"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/na4ma4/config"
 )
 
 const (
 	defaultTimeout = 10 * time.Second
+
+	defaultUserLogin = "octocat"
+
+	tokenContextKey = "mockghauth.token"
+
+	defaultUserTokenExpire    = 8 * time.Hour
+	defaultRefreshTokenExpire = 184 * 24 * time.Hour
+
+	grantTypeAuthorizationCode = "authorization_code"
+	grantTypeRefreshToken      = "refresh_token"
 )
 
 type Server struct {
-	baseURL *url.URL
-	clients *Clients
-	codes   *Codes
-	tokens  *Tokens
-	g       *gin.Engine
+	baseURL      *url.URL
+	apiURL       *url.URL
+	apiAddress   string
+	apps         *Apps
+	clients      *Clients
+	codes        *Codes
+	devices      *DeviceCodes
+	orgs         *Orgs
+	repos        *Repos
+	tokens       *Tokens
+	refresh      *Tokens
+	users        *Users
+	oidc         *OIDCIssuer
+	defaultUser  string
+	requireState bool
+	interactive  bool
+	tokenExpire  time.Duration
+	userExpire   time.Duration
+	refreshTTL   time.Duration
+	g            *gin.Engine
+	api          *gin.Engine
 }
 
 //nolint:forbidigo // panic error.
 func NewServer(baseURL *url.URL, cfg config.Conf) *Server {
 	g := gin.Default() // listen on 0.0.0.0:8080
 	codes := &Codes{}
+	devices := &DeviceCodes{}
 	clients := NewClients()
+	apps := NewApps()
 	tokens := &Tokens{}
+	refresh := &Tokens{}
+	users := NewUsers()
+	orgs := NewOrgs()
+	repos := NewRepos()
+
+	apiMode := APIMode(cfg.GetString("api.mode"))
+
+	apiURL, apiPrefix, apiErr := apiLayout(apiMode, baseURL, cfg.GetString("api.bind"))
+	if apiErr != nil {
+		fmt.Printf("unable to configure api: %s\n", apiErr)
+		panic(apiErr)
+	}
 
 	s := &Server{
-		baseURL: baseURL,
-		g:       g,
-		codes:   codes,
-		tokens:  tokens,
-		clients: clients,
+		baseURL:      baseURL,
+		apiURL:       apiURL,
+		g:            g,
+		codes:        codes,
+		devices:      devices,
+		orgs:         orgs,
+		repos:        repos,
+		tokens:       tokens,
+		refresh:      refresh,
+		clients:      clients,
+		apps:         apps,
+		users:        users,
+		defaultUser:  cfg.GetString("users.default-login"),
+		requireState: cfg.GetBool("oauth.require-state"),
+		interactive:  cfg.GetBool("oauth.interactive"),
+		tokenExpire:  cfg.GetDuration("oauth.token-expire"),
+		userExpire:   cfg.GetDuration("oauth.user-token-expire"),
+		refreshTTL:   cfg.GetDuration("oauth.refresh-token-expire"),
 	}
 
+	g.SetHTMLTemplate(template.Must(template.ParseFS(staticsrc.Content, "*.html")))
+
+	if s.defaultUser == "" {
+		s.defaultUser = defaultUserLogin
+	}
+
+	if exp := cfg.GetDuration("oauth.code-expire"); exp > 0 {
+		codes.SetExpire(exp)
+	}
+
+	if exp := cfg.GetDuration("oauth.device-code-expire"); exp > 0 {
+		devices.SetExpire(exp)
+	}
+
+	if interval := cfg.GetDuration("oauth.device-interval"); interval > 0 {
+		devices.SetInterval(interval)
+	}
+
+	if s.userExpire <= 0 {
+		s.userExpire = defaultUserTokenExpire
+	}
+
+	if s.refreshTTL <= 0 {
+		s.refreshTTL = defaultRefreshTokenExpire
+	}
+
+	refresh.SetExpire(s.refreshTTL)
+
 	if filename := cfg.GetString("load.code-file"); filename != "" {
 		if err := codes.ReadFile(filename); err != nil {
 			fmt.Printf("unable to load file[%s]: %s\n", filename, err)
@@ -54,6 +140,13 @@ func NewServer(baseURL *url.URL, cfg config.Conf) *Server {
 		}
 	}
 
+	if filename := cfg.GetString("load.apps-file"); filename != "" {
+		if err := apps.ReadFile(filename); err != nil {
+			fmt.Printf("unable to load file[%s]: %s\n", filename, err)
+			panic(err)
+		}
+	}
+
 	if filename := cfg.GetString("load.tokens-file"); filename != "" {
 		if err := tokens.ReadFile(filename); err != nil {
 			fmt.Printf("unable to load file[%s]: %s\n", filename, err)
@@ -61,96 +154,560 @@ func NewServer(baseURL *url.URL, cfg config.Conf) *Server {
 		}
 	}
 
+	var oidcKey *rsa.PrivateKey
+	if filename := cfg.GetString("load.oidc-key-file"); filename != "" {
+		key, err := ReadOIDCKeyFile(filename)
+		if err != nil {
+			fmt.Printf("unable to load file[%s]: %s\n", filename, err)
+			panic(err)
+		}
+
+		oidcKey = key
+	}
+
+	oidcIssuer := cfg.GetString("oidc.issuer")
+	if oidcIssuer == "" {
+		oidcIssuer = baseURL.String()
+	}
+
+	s.oidc = NewOIDCIssuer(oidcIssuer, oidcKey, cfg.GetDuration("oidc.token-expire"), OIDCDefaults{
+		Repository: cfg.GetString("oidc.repository"),
+		Ref:        cfg.GetString("oidc.ref"),
+		Workflow:   cfg.GetString("oidc.workflow"),
+		Actor:      s.defaultUser,
+		Audience:   cfg.GetString("oidc.audience"),
+	})
+
+	if filename := cfg.GetString("load.users-file"); filename != "" {
+		if err := users.ReadFile(filename); err != nil {
+			fmt.Printf("unable to load file[%s]: %s\n", filename, err)
+			panic(err)
+		}
+	} else {
+		user, err := DefaultGitHubAPIUserForAPI(baseURL, apiURL)
+		if err != nil {
+			fmt.Printf("unable to load default user: %s\n", err)
+			panic(err)
+		}
+
+		users.Add(&User{GitHubAPIUser: *user})
+	}
+
+	if filename := cfg.GetString("load.orgs-file"); filename != "" {
+		if err := orgs.ReadFile(filename); err != nil {
+			fmt.Printf("unable to load file[%s]: %s\n", filename, err)
+			panic(err)
+		}
+	}
+
+	if filename := cfg.GetString("load.repos-file"); filename != "" {
+		if err := repos.ReadFile(filename); err != nil {
+			fmt.Printf("unable to load file[%s]: %s\n", filename, err)
+			panic(err)
+		}
+	}
+
 	g.GET("/login/oauth/authorize", s.loginOauthAuthorize)
+	g.POST("/login/oauth/authorize", s.loginOauthAuthorizeSubmit)
+	g.GET("/_mock/codes/:code", s.mockCode)
+	g.POST("/_mock/tokens", s.mockTokens)
+	g.GET("/_mock/oidc/token", s.mockOIDCToken)
+	g.POST("/_mock/oidc/token", s.mockOIDCToken)
+	g.GET("/.well-known/openid-configuration", s.wellKnownOpenIDConfiguration)
+	g.GET("/.well-known/jwks", s.wellKnownJWKS)
 	g.POST("/login/oauth/access_token", s.loginOauthAccessToken)
-	g.GET("/api/v3/user", s.apiV3User)
+	g.POST("/login/device/code", s.loginDeviceCode)
+	g.GET("/login/device", s.loginDevice)
+	g.POST("/login/device", s.loginDeviceSubmit)
+	g.POST("/_mock/device/:user_code/approve", s.mockDeviceApprove)
+	g.POST("/_mock/device/:user_code/deny", s.mockDeviceDeny)
+	api := g.Group(apiPrefix)
+	if apiMode == APIModeHost {
+		s.api = gin.Default()
+		s.apiAddress = listenAddress(cfg.GetString("api.bind"))
+		api = s.api.Group(apiPrefix)
+	}
+
+	api.GET("/user", s.requireAuth(), s.apiV3User)
+	api.GET("/user/emails", s.requireAuth(), s.requireScopes(http.StatusNotFound, "user:email"),
+		s.apiV3UserEmails)
+	api.GET("/user/public_emails", s.requireAuth(), s.requireScopes(http.StatusNotFound, "user:email"),
+		s.apiV3UserPublicEmails)
+	api.POST("/applications/:client_id/token", s.requireClientAuth(), s.applicationsCheckToken)
+	api.PATCH("/applications/:client_id/token", s.requireClientAuth(), s.applicationsResetToken)
+	api.DELETE("/applications/:client_id/token", s.requireClientAuth(), s.applicationsDeleteToken)
+	api.DELETE("/applications/:client_id/grant", s.requireClientAuth(), s.applicationsDeleteGrant)
+	api.GET("/app", s.requireAppAuth(), s.apiV3App)
+	api.GET("/app/installations", s.requireAppAuth(), s.apiV3AppInstallations)
+	api.GET("/app/installations/:installation_id", s.requireAppAuth(), s.apiV3AppInstallation)
+	api.POST("/app/installations/:installation_id/access_tokens", s.requireAppAuth(),
+		s.apiV3AppInstallationAccessTokens)
+	api.GET("/installation/repositories", s.requireAuth(), s.apiV3InstallationRepositories)
+	api.GET("/user/orgs", s.requireAuth(), s.requireScopes(http.StatusForbidden, "read:org", "user"), s.apiV3UserOrgs)
+	api.GET("/user/teams", s.requireAuth(),
+		s.requireScopes(http.StatusForbidden, "read:org", "repo", "user"), s.apiV3UserTeams)
+	api.GET("/orgs/:org/members/:username", s.optionalAuth(), s.apiV3OrgMember)
+	api.GET("/orgs/:org/public_members/:username", s.optionalAuth(), s.apiV3OrgPublicMember)
+	api.GET("/orgs/:org/memberships/:username", s.requireAuth(),
+		s.requireScopes(http.StatusForbidden, "read:org"), s.apiV3OrgMembership)
+	api.GET("/orgs/:org/teams/:team_slug/memberships/:username", s.requireAuth(),
+		s.requireScopes(http.StatusForbidden, "read:org"), s.apiV3OrgTeamMembership)
+	api.GET("/user/repos", s.requireAuth(), s.apiV3UserRepos)
+	api.GET("/repos/:owner/:repo", s.optionalAuth(), s.apiV3Repo)
+	api.GET("/repos/:owner/:repo/collaborators/:username/permission", s.requireAuth(),
+		s.apiV3RepoCollaboratorPermission)
+	api.GET("/users/:username", s.optionalAuth(), s.apiV3Users)
+	api.GET("/users/:username/orgs", s.optionalAuth(), s.apiV3UsersOrgs)
+	api.GET("/users/:username/repos", s.optionalAuth(), s.apiV3UsersRepos)
+	api.GET("/users/:username/followers", s.optionalAuth(), s.apiV3UsersFollowers)
+	api.GET("/users/:username/following", s.optionalAuth(), s.apiV3UsersFollowing)
+	api.GET("/users/:username/following/:target_user", s.optionalAuth(), s.apiV3UsersFollowingUser)
 
 	return s
 }
 
-func (s *Server) loginOauthAuthorize(c *gin.Context) {
-	clientID, clientIDExists := c.GetQuery("client_id")
-	if !clientIDExists || !s.clients.HasID(clientID) {
-		c.AbortWithStatus(http.StatusUnauthorized)
+func (s *Server) loginOauthAccessToken(c *gin.Context) {
+	var oauthReq GitHubOAuth
+
+	if err := bindOAuthRequest(c, &oauthReq); err != nil {
+		renderOAuth(c, http.StatusBadRequest,
+			NewOAuthError(OAuthErrorInvalidRequest).WithDescription("The request could not be parsed: "+err.Error()),
+		)
+		return
 	}
 
-	redirectURI, redirectURIExists := c.GetQuery("redirect_uri")
-	if !redirectURIExists {
-		c.AbortWithStatus(http.StatusBadRequest)
+	// The device flow is used by clients that cannot keep a secret, so only
+	// the client_id is required when polling a device code.
+	client, clientExists := s.clients.Get(oauthReq.ClientID)
+	if !clientExists || (oauthReq.GrantType != grantTypeDeviceCode && !client.VerifySecret(oauthReq.ClientSecret)) {
+		renderOAuth(c, http.StatusOK, NewOAuthError(OAuthErrorIncorrectClientCredentials))
+		return
 	}
 
-	code := s.codes.New()
+	if client.Suspended {
+		renderOAuth(c, http.StatusOK, NewOAuthError(OAuthErrorApplicationSuspended))
+		return
+	}
 
-	c.Redirect(http.StatusFound, fmt.Sprintf("%s?code=%s", redirectURI, code))
+	switch oauthReq.GrantType {
+	case "", grantTypeAuthorizationCode:
+		s.grantAuthorizationCode(c, client, &oauthReq)
+	case grantTypeRefreshToken:
+		s.grantRefreshToken(c, client, &oauthReq)
+	case grantTypeDeviceCode:
+		s.grantDeviceCode(c, client, &oauthReq)
+	default:
+		renderOAuth(c, http.StatusOK, NewOAuthError(OAuthErrorUnsupportedGrantType))
+	}
 }
 
-func (s *Server) loginOauthAccessToken(c *gin.Context) {
-	var oauthReq GitHubOAuth
+// grantAuthorizationCode exchanges an authorization code for a token.
+func (s *Server) grantAuthorizationCode(c *gin.Context, client *Client, oauthReq *GitHubOAuth) {
+	if oauthReq.RedirectURI != "" {
+		if _, err := client.ResolveRedirectURI(oauthReq.RedirectURI); err != nil {
+			renderOAuth(c, http.StatusOK, NewOAuthError(OAuthErrorRedirectURIMismatch))
+			return
+		}
+	}
 
-	if err := c.ShouldBind(&oauthReq); err != nil {
-		_ = c.AbortWithError(http.StatusInternalServerError, err)
+	code, err := s.codes.Exchange(oauthReq)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRedirectURIMismatch):
+			renderOAuth(c, http.StatusOK, NewOAuthError(OAuthErrorRedirectURIMismatch))
+		case errors.Is(err, ErrCodeVerifierMismatch):
+			renderOAuth(c, http.StatusOK, NewOAuthError(OAuthErrorBadVerificationCode).WithDescription(
+				"The code_verifier does not match the code_challenge.",
+			))
+		default:
+			renderOAuth(c, http.StatusOK, NewOAuthError(OAuthErrorBadVerificationCode))
+		}
 		return
 	}
 
-	if !s.clients.HasID(oauthReq.ClientID) {
-		c.AbortWithStatus(http.StatusNotFound)
+	renderOAuth(c, http.StatusOK, s.issueToken(client, code.User, ParseScopes(code.Scope)))
+}
+
+// grantRefreshToken rotates a refresh token, issuing a new access token and
+// refresh token and invalidating the one presented.
+func (s *Server) grantRefreshToken(c *gin.Context, client *Client, oauthReq *GitHubOAuth) {
+	if !client.ExpiringTokens || oauthReq.RefreshToken == "" {
+		renderOAuth(c, http.StatusOK, NewOAuthError(OAuthErrorBadRefreshToken))
 		return
 	}
 
-	if !s.codes.Exists(oauthReq.Code) {
-		c.AbortWithStatus(http.StatusNotFound)
+	tok, err := s.refresh.Consume(oauthReq.RefreshToken, client.ID)
+	if err != nil {
+		renderOAuth(c, http.StatusOK, NewOAuthError(OAuthErrorBadRefreshToken))
 		return
 	}
 
-	token := s.tokens.New()
+	renderOAuth(c, http.StatusOK, s.issueToken(client, tok.User, tok.Scopes))
+}
 
-	resp := &GitHubOAuthResponse{
-		AccessToken: token,
-		Scope:       "repo,admin",
-		TokenType:   "bearer",
+// issueToken issues an access token for user to client, along with a refresh
+// token when the client uses expiring user-to-server tokens. OAuth app tokens
+// do not expire unless oauth.token-expire is set.
+func (s *Server) issueToken(client *Client, user string, scopes Scopes) *GitHubOAuthResponse {
+	tok := &Token{
+		Kind:     TokenKindOAuth,
+		ClientID: client.ID,
+		User:     user,
+		Scopes:   scopes,
 	}
 
-	c.JSON(http.StatusOK, resp)
+	if !client.ExpiringTokens {
+		return &GitHubOAuthResponse{
+			AccessToken: s.tokens.IssueWithExpire(tok, s.tokenExpire),
+			Scope:       scopes.String(),
+			TokenType:   "bearer",
+		}
+	}
+
+	tok.Kind = TokenKindUserToServer
+	accessToken := s.tokens.IssueWithExpire(tok, s.userExpire)
+
+	tok.Kind = TokenKindRefresh
+	refreshToken := s.refresh.Issue(tok)
+
+	return &GitHubOAuthResponse{
+		AccessToken:           accessToken,
+		ExpiresIn:             int(s.userExpire.Seconds()),
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresIn: int(s.refreshTTL.Seconds()),
+		Scope:                 scopes.String(),
+		TokenType:             "bearer",
+	}
+}
+
+// bindOAuthRequest reads a token request from the query string, then a form or
+// JSON body, and finally HTTP Basic client authentication for any client
+// credentials not already supplied.
+func bindOAuthRequest(c *gin.Context, req *GitHubOAuth) error {
+	if err := c.ShouldBindQuery(req); err != nil {
+		return err
+	}
+
+	if c.Request.Body != nil && c.Request.ContentLength != 0 {
+		switch c.ContentType() {
+		case gin.MIMEJSON:
+			if err := c.ShouldBindWith(req, binding.JSON); err != nil {
+				return err
+			}
+		case gin.MIMEPOSTForm:
+			if err := c.ShouldBindWith(req, binding.Form); err != nil {
+				return err
+			}
+		case gin.MIMEMultipartPOSTForm:
+			if err := c.ShouldBindWith(req, binding.FormMultipart); err != nil {
+				return err
+			}
+		}
+	}
+
+	if id, secret, ok := c.Request.BasicAuth(); ok {
+		if req.ClientID == "" {
+			req.ClientID = id
+		}
+
+		if req.ClientSecret == "" {
+			req.ClientSecret = secret
+		}
+	}
+
+	return nil
 }
 
+// oauthBody is a token endpoint response that can be form-encoded.
+type oauthBody interface {
+	Values() url.Values
+}
+
+// renderOAuth writes body the way GitHub's token endpoint does: form-encoded
+// unless the Accept header asks for JSON or XML.
+func renderOAuth(c *gin.Context, code int, body oauthBody) {
+	switch c.NegotiateFormat(gin.MIMEPOSTForm, gin.MIMEJSON, gin.MIMEXML, gin.MIMEXML2) {
+	case gin.MIMEJSON:
+		c.JSON(code, body)
+	case gin.MIMEXML, gin.MIMEXML2:
+		c.XML(code, body)
+	default:
+		c.Data(code, gin.MIMEPOSTForm+"; charset=utf-8", []byte(body.Values().Encode()))
+	}
+}
+
+// checkAuthIsValid returns the token presented in the Authorization header.
+// A missing header yields the GitHub "Must authenticate" error, a malformed
+// header or an unknown or expired token the "Bad credentials" error.
+//
 //nolint:mnd // get everything after first space in Authorization header.
-func (s *Server) checkAuthIsValid(c *gin.Context) bool {
-	if authHeader := c.Request.Header.Get("Authorization"); authHeader != "" {
-		spHeader := strings.SplitN(authHeader, " ", 2)
-		if len(spHeader) != 2 {
-			return false
+func (s *Server) checkAuthIsValid(c *gin.Context) (*Token, *GitHubAPIError) {
+	authHeader := c.Request.Header.Get("Authorization")
+	if authHeader == "" {
+		return nil, UnauthorizedGitHubAPIError()
+	}
+
+	spHeader := strings.SplitN(authHeader, " ", 2)
+	if len(spHeader) != 2 {
+		return nil, BadCredentialsGitHubAPIError()
+	}
+
+	tok, ok := s.tokens.Get(spHeader[1])
+	if !ok || tok.Expired(time.Now()) {
+		return nil, BadCredentialsGitHubAPIError()
+	}
+
+	return tok, nil
+}
+
+// requireAuth authenticates the request against the issued tokens, storing
+// the token in the context and reporting the granted scopes in the
+// X-OAuth-Scopes header.
+func (s *Server) requireAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tok, apiErr := s.checkAuthIsValid(c)
+		if apiErr != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, apiErr)
+			return
 		}
-		if !s.tokens.Exists(spHeader[1]) {
-			return false
+
+		// Set directly so the headers are sent even when empty, as GitHub does.
+		c.Writer.Header().Set("X-OAuth-Scopes", tok.Scopes.Header())
+		c.Writer.Header().Set("X-Accepted-OAuth-Scopes", "")
+		c.Set(tokenContextKey, tok)
+		c.Next()
+	}
+}
+
+// optionalAuth authenticates requests carrying an Authorization header like
+// requireAuth and lets anonymous requests through.
+func (s *Server) optionalAuth() gin.HandlerFunc {
+	authenticate := s.requireAuth()
+
+	return func(c *gin.Context) {
+		if c.Request.Header.Get("Authorization") == "" {
+			c.Next()
+			return
+		}
+
+		authenticate(c)
+	}
+}
+
+// requireScopes rejects requests whose token holds none of scopes with status
+// (404 for resources GitHub hides, 403 otherwise), and reports scopes in the
+// X-Accepted-OAuth-Scopes header. It must follow requireAuth.
+func (s *Server) requireScopes(status int, scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Writer.Header().Set("X-Accepted-OAuth-Scopes", Scopes(scopes).Header())
+
+		tok := contextToken(c)
+		if !tok.Scopes.HasAny(scopes...) {
+			switch {
+			case status == http.StatusNotFound:
+				c.AbortWithStatusJSON(status, NotFoundGitHubAPIError())
+			case tok.Kind == TokenKindInstallation:
+				c.AbortWithStatusJSON(status, ForbiddenGitHubAPIError())
+			default:
+				c.AbortWithStatusJSON(status, ScopesGitHubAPIError(scopes, tok.Scopes))
+			}
+
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// contextToken returns the token stored by requireAuth.
+func contextToken(c *gin.Context) *Token {
+	if v, ok := c.Get(tokenContextKey); ok {
+		if tok, isToken := v.(*Token); isToken {
+			return tok
 		}
-	} else {
-		return false
 	}
 
-	return true
+	return &Token{}
+}
+
+// contextUser returns the user the request token was issued to, tokens
+// without a user belong to the default user.
+func (s *Server) contextUser(c *gin.Context) (*User, bool) {
+	login := contextToken(c).User
+	if login == "" {
+		login = s.defaultUser
+	}
+
+	return s.users.Get(login)
+}
+
+// contextAuthenticatedUser returns the user for the /user endpoints, which
+// installation tokens cannot use.
+//
+// Like the other context helpers, it writes the error response itself and
+// returns false when the request cannot go on.
+func (s *Server) contextAuthenticatedUser(c *gin.Context) (*User, bool) {
+	if contextToken(c).Kind == TokenKindInstallation {
+		c.AbortWithStatusJSON(http.StatusForbidden, ForbiddenGitHubAPIError())
+		return nil, false
+	}
+
+	user, ok := s.contextUser(c)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, BadCredentialsGitHubAPIError())
+		return nil, false
+	}
+
+	return user, true
 }
 
 func (s *Server) apiV3User(c *gin.Context) {
-	if !s.checkAuthIsValid(c) {
-		c.AbortWithStatusJSON(http.StatusUnauthorized, UnauthorizedGitHubAPIError())
+	user, ok := s.contextAuthenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, s.apiUser(user, contextToken(c).Scopes))
+}
+
+func (s *Server) apiV3UserEmails(c *gin.Context) {
+	user, ok := s.contextAuthenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, user.EmailAddresses())
+}
+
+func (s *Server) apiV3UserPublicEmails(c *gin.Context) {
+	user, ok := s.contextAuthenticatedUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, user.PublicEmailAddresses())
+}
+
+// mockCode returns an issued, not yet exchanged, code and the authorization
+// request it records.
+func (s *Server) mockCode(c *gin.Context) {
+	code, ok := s.codes.Get(c.Param("code"))
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusNotFound, NotFoundGitHubAPIError())
+		return
+	}
+
+	c.JSON(http.StatusOK, code)
+}
+
+// mockTokenRequest is the body of a request to mint a personal access token.
+type mockTokenRequest struct {
+	Kind   TokenKind `json:"kind"`
+	User   string    `json:"user"`
+	Scopes string    `json:"scopes"`
+}
+
+// mockTokens mints a personal access token for a fixture user, the way a user
+// would create one in their settings.
+func (s *Server) mockTokens(c *gin.Context) {
+	var req mockTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, &GitHubAPIError{
+			Message:          "Problems parsing JSON",
+			DocumentationURL: apiDocumentationURL,
+		})
 		return
 	}
 
-	user, err := DefaultGitHubAPIUser(s.baseURL)
+	if req.Kind == "" {
+		req.Kind = TokenKindPersonal
+	}
+
+	token, err := s.IssuePersonalAccessToken(req.User, req.Kind, ParseScopes(req.Scopes))
 	if err != nil {
-		c.AbortWithStatusJSON(http.StatusInternalServerError, UnauthorizedGitHubAPIError(err))
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, &GitHubAPIError{
+			Message:          err.Error(),
+			DocumentationURL: apiDocumentationURL,
+		})
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	c.JSON(http.StatusCreated, gin.H{"token": token})
+}
+
+// IssuePersonalAccessToken issues a classic (TokenKindPersonal) or
+// fine-grained (TokenKindFineGrained) personal access token for login.
+func (s *Server) IssuePersonalAccessToken(login string, kind TokenKind, scopes Scopes) (string, error) {
+	if kind != TokenKindPersonal && kind != TokenKindFineGrained {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedTokenKind, kind)
+	}
+
+	user, ok := s.users.Get(login)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUserNotFound, login)
+	}
+
+	return s.tokens.Issue(&Token{
+		Kind:   kind,
+		User:   user.Login,
+		Scopes: scopes,
+	}), nil
+}
+
+// Code returns an issued, not yet exchanged, code.
+func (s *Server) Code(code string) (*Code, bool) {
+	return s.codes.Get(code)
 }
 
 func (s *Server) AddClient(id, secret string) {
 	s.clients.Add(id, secret)
 }
 
+// AddApp adds or replaces a fixture GitHub App.
+func (s *Server) AddApp(app *App) error {
+	return s.apps.Add(app)
+}
+
+// AddOrg adds or replaces a fixture organization.
+func (s *Server) AddOrg(org *Org) {
+	s.orgs.Add(org)
+}
+
+// AddRepo adds or replaces a fixture repository.
+func (s *Server) AddRepo(repo *Repo) {
+	s.repos.Add(repo)
+}
+
+// AddUser adds or replaces a fixture user.
+func (s *Server) AddUser(user *User) {
+	s.users.Add(user)
+}
+
+// RegisterClient adds or replaces a fully specified client.
+func (s *Server) RegisterClient(client *Client) {
+	s.clients.Set(client)
+}
+
 func (s *Server) Reaper(ts time.Time) {
+	s.codes.Reaper(ts)
 	s.tokens.Reaper(ts)
+	s.refresh.Reaper(ts)
+	s.devices.Reaper(ts)
+}
+
+// Handler returns the http.Handler serving the mock endpoints.
+func (s *Server) Handler() http.Handler {
+	return s.g.Handler()
+}
+
+// APIHandler returns the http.Handler serving the REST API, which is Handler
+// unless the API is served from a separate host.
+func (s *Server) APIHandler() http.Handler {
+	if s.api != nil {
+		return s.api.Handler()
+	}
+
+	return s.Handler()
 }
 
 func (s *Server) Run(ctx context.Context) error {
@@ -159,20 +716,17 @@ func (s *Server) Run(ctx context.Context) error {
 		address = ":" + port
 	}
 
-	srv := &http.Server{
-		Addr:              address,
-		Handler:           s.g.Handler(),
-		ReadTimeout:       defaultTimeout,
-		ReadHeaderTimeout: defaultTimeout,
-		WriteTimeout:      defaultTimeout,
-		IdleTimeout:       defaultTimeout,
-	}
-
-	doneCh := make(chan error)
+	doneCh := make(chan error, 2) //nolint:mnd // main and API listeners.
 	go func() {
-		doneCh <- srv.ListenAndServe()
+		doneCh <- newHTTPServer(address, s.Handler()).ListenAndServe()
 	}()
 
+	if s.api != nil {
+		go func() {
+			doneCh <- newHTTPServer(s.apiAddress, s.api.Handler()).ListenAndServe()
+		}()
+	}
+
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
@@ -180,3 +734,14 @@ func (s *Server) Run(ctx context.Context) error {
 		return err
 	}
 }
+
+func newHTTPServer(address string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              address,
+		Handler:           handler,
+		ReadTimeout:       defaultTimeout,
+		ReadHeaderTimeout: defaultTimeout,
+		WriteTimeout:      defaultTimeout,
+		IdleTimeout:       defaultTimeout,
+	}
+}
@@ -0,0 +1,2608 @@
+package mockghauth_test
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"html"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dosquad/mock-oauth-test-server/mockghauth"
+	"github.com/gin-gonic/gin"
+	"github.com/na4ma4/config"
+	"github.com/spf13/viper"
+)
+
+func newTestServer(t *testing.T, settings map[string]any) *mockghauth.Server {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+
+	v := viper.New()
+	for k, val := range settings {
+		v.Set(k, val)
+	}
+
+	baseURL, err := url.Parse("http://localhost:8080")
+	if err != nil {
+		t.Fatalf("url.Parse() error = %v", err)
+	}
+
+	svr := mockghauth.NewServer(baseURL, config.NewViperConfigFromViper(v, "mock-server"))
+	svr.AddClient("test-client", "secret")
+
+	return svr
+}
+
+func doRequest(t *testing.T, svr *mockghauth.Server, req *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	svr.Handler().ServeHTTP(w, req)
+
+	return w
+}
+
+func authorizeRedirect(t *testing.T, svr *mockghauth.Server, query url.Values) url.Values {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, "/login/oauth/authorize?"+query.Encode(), nil)
+	w := doRequest(t, svr, req)
+
+	if w.Code != http.StatusFound {
+		t.Fatalf("authorize status = %d, expected = %d", w.Code, http.StatusFound)
+	}
+
+	loc, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("authorize Location parse error = %v", err)
+	}
+
+	return loc.Query()
+}
+
+func authorizeCode(t *testing.T, svr *mockghauth.Server, query url.Values) string {
+	t.Helper()
+
+	params := authorizeRedirect(t, svr, query)
+
+	code := params.Get("code")
+	if code == "" {
+		t.Fatalf("authorize code is empty, error = %q", params.Get("error"))
+	}
+
+	return code
+}
+
+func accessTokenJSON(t *testing.T, svr *mockghauth.Server, body map[string]string) map[string]any {
+	t.Helper()
+
+	return postOAuthJSON(t, svr, "/login/oauth/access_token", body)
+}
+
+func postOAuthJSON(t *testing.T, svr *mockghauth.Server, path string, body map[string]string) map[string]any {
+	t.Helper()
+
+	buf, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(buf))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	w := doRequest(t, svr, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s status = %d, expected = %d", path, w.Code, http.StatusOK)
+	}
+
+	out := map[string]any{}
+	if decodeErr := json.NewDecoder(w.Body).Decode(&out); decodeErr != nil {
+		t.Fatalf("%s decode error = %v", path, decodeErr)
+	}
+
+	return out
+}
+
+func issueToken(t *testing.T, svr *mockghauth.Server, query url.Values) map[string]any {
+	t.Helper()
+
+	query.Set("client_id", "test-client")
+	query.Set("redirect_uri", "http://localhost/callback")
+
+	return accessTokenJSON(t, svr, map[string]string{
+		"client_id":     "test-client",
+		"client_secret": "secret",
+		"code":          authorizeCode(t, svr, query),
+	})
+}
+
+func apiRequest(t *testing.T, svr *mockghauth.Server, path, token string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "token "+token)
+	}
+
+	return doRequest(t, svr, req)
+}
+
+func personalToken(t *testing.T, svr *mockghauth.Server, login string, scopes ...string) string {
+	t.Helper()
+
+	token, err := svr.IssuePersonalAccessToken(login, mockghauth.TokenKindPersonal, scopes)
+	if err != nil {
+		t.Fatalf("Server.IssuePersonalAccessToken() error = %v", err)
+	}
+
+	return token
+}
+
+func TestServer_AuthorizeState(t *testing.T) {
+	tests := []struct {
+		name      string
+		settings  map[string]any
+		state     string
+		wantState string
+		wantError string
+		wantCode  bool
+	}{
+		{
+			name:      "state is echoed",
+			state:     "xyz-state",
+			wantState: "xyz-state",
+			wantCode:  true,
+		},
+		{
+			name:     "state is optional by default",
+			wantCode: true,
+		},
+		{
+			name:      "state is required in strict mode",
+			settings:  map[string]any{"oauth.require-state": true},
+			wantError: "invalid_request",
+		},
+		{
+			name:      "state is echoed in strict mode",
+			settings:  map[string]any{"oauth.require-state": true},
+			state:     "abc",
+			wantState: "abc",
+			wantCode:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svr := newTestServer(t, tt.settings)
+
+			query := url.Values{
+				"client_id":    []string{"test-client"},
+				"redirect_uri": []string{"http://localhost/callback?keep=1"},
+			}
+			if tt.state != "" {
+				query.Set("state", tt.state)
+			}
+
+			params := authorizeRedirect(t, svr, query)
+
+			if v := params.Get("state"); v != tt.wantState {
+				t.Errorf("authorize state = %q, expected = %q", v, tt.wantState)
+			}
+
+			if v := params.Get("error"); v != tt.wantError {
+				t.Errorf("authorize error = %q, expected = %q", v, tt.wantError)
+			}
+
+			if v := params.Get("code"); (v != "") != tt.wantCode {
+				t.Errorf("authorize code = %q, expected code = %t", v, tt.wantCode)
+			}
+
+			if v := params.Get("keep"); v != "1" {
+				t.Errorf("authorize keep = %q, expected redirect_uri query to be kept", v)
+			}
+		})
+	}
+}
+
+func TestServer_AuthorizeRedirectURI(t *testing.T) {
+	tests := []struct {
+		name        string
+		redirectURI string
+		wantPath    string
+		wantError   string
+	}{
+		{"registered callback used when omitted", "", "/path", ""},
+		{"sub-path accepted", "http://example.com/path/sub", "/path/sub", ""},
+		{"mismatch redirects to callback", "http://example.com/other", "/path", "redirect_uri_mismatch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svr := newTestServer(t, nil)
+			svr.RegisterClient(&mockghauth.Client{
+				ID:          "callback-client",
+				Secret:      "secret",
+				CallbackURL: "http://example.com/path",
+			})
+
+			query := url.Values{
+				"client_id": []string{"callback-client"},
+				"state":     []string{"st"},
+			}
+			if tt.redirectURI != "" {
+				query.Set("redirect_uri", tt.redirectURI)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/login/oauth/authorize?"+query.Encode(), nil)
+			w := doRequest(t, svr, req)
+
+			loc, err := url.Parse(w.Header().Get("Location"))
+			if err != nil {
+				t.Fatalf("authorize Location parse error = %v", err)
+			}
+
+			if loc.Path != tt.wantPath {
+				t.Errorf("authorize redirect path = %q, expected = %q", loc.Path, tt.wantPath)
+			}
+
+			if v := loc.Query().Get("error"); v != tt.wantError {
+				t.Errorf("authorize error = %q, expected = %q", v, tt.wantError)
+			}
+
+			if v := loc.Query().Get("state"); v != "st" {
+				t.Errorf("authorize state = %q, expected = %q", v, "st")
+			}
+		})
+	}
+}
+
+func TestServer_AccessTokenClientCredentials(t *testing.T) {
+	tests := []struct {
+		name      string
+		clientID  string
+		secret    string
+		wantError string
+	}{
+		{"correct secret", "test-client", "secret", ""},
+		{"secret is case-sensitive", "test-client", "SECRET", "incorrect_client_credentials"},
+		{"wrong secret", "test-client", "other", "incorrect_client_credentials"},
+		{"unknown client", "unknown-client", "secret", "incorrect_client_credentials"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svr := newTestServer(t, nil)
+
+			code := authorizeCode(t, svr, url.Values{
+				"client_id":    []string{"test-client"},
+				"redirect_uri": []string{"http://localhost/callback"},
+			})
+
+			resp := accessTokenJSON(t, svr, map[string]string{
+				"client_id":     tt.clientID,
+				"client_secret": tt.secret,
+				"code":          code,
+			})
+
+			if v, _ := resp["error"].(string); v != tt.wantError {
+				t.Errorf("access_token error = %q, expected = %q", v, tt.wantError)
+			}
+
+			if tt.wantError != "" {
+				for _, key := range []string{"error_description", "error_uri"} {
+					if v, _ := resp[key].(string); v == "" {
+						t.Errorf("access_token %s is empty", key)
+					}
+				}
+				return
+			}
+
+			if v, _ := resp["access_token"].(string); v == "" {
+				t.Errorf("access_token access_token is empty")
+			}
+		})
+	}
+}
+
+func TestServer_AccessTokenCodeIsSingleUse(t *testing.T) {
+	svr := newTestServer(t, nil)
+	svr.AddClient("other-client", "secret")
+
+	code := authorizeCode(t, svr, url.Values{
+		"client_id":    []string{"test-client"},
+		"redirect_uri": []string{"http://localhost/callback"},
+	})
+
+	resp := accessTokenJSON(t, svr, map[string]string{
+		"client_id":     "other-client",
+		"client_secret": "secret",
+		"code":          code,
+	})
+	if v, _ := resp["error"].(string); v != "bad_verification_code" {
+		t.Errorf("access_token other client error = %q, expected = %q", v, "bad_verification_code")
+	}
+
+	resp = accessTokenJSON(t, svr, map[string]string{
+		"client_id":     "test-client",
+		"client_secret": "secret",
+		"code":          code,
+	})
+	if v, _ := resp["access_token"].(string); v == "" {
+		t.Errorf("access_token first exchange failed, error = %v", resp["error"])
+	}
+
+	resp = accessTokenJSON(t, svr, map[string]string{
+		"client_id":     "test-client",
+		"client_secret": "secret",
+		"code":          code,
+	})
+	if v, _ := resp["error"].(string); v != "bad_verification_code" {
+		t.Errorf("access_token reuse error = %q, expected = %q", v, "bad_verification_code")
+	}
+}
+
+func TestServer_AccessTokenNegotiation(t *testing.T) {
+	tests := []struct {
+		name        string
+		accept      string
+		secret      string
+		wantType    string
+		wantContain string
+	}{
+		{"default is form-encoded", "", "secret", "application/x-www-form-urlencoded", "token_type=bearer"},
+		{"wildcard is form-encoded", "*/*", "secret", "application/x-www-form-urlencoded", "token_type=bearer"},
+		{"json", "application/json", "secret", "application/json", `"token_type":"bearer"`},
+		{"xml", "application/xml", "secret", "application/xml", "<token_type>bearer</token_type>"},
+		{
+			"form-encoded error", "", "wrong", "application/x-www-form-urlencoded",
+			"error=incorrect_client_credentials",
+		},
+		{"json error", "application/json", "wrong", "application/json", `"error":"incorrect_client_credentials"`},
+		{"xml error", "application/xml", "wrong", "application/xml", "<error>incorrect_client_credentials</error>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svr := newTestServer(t, nil)
+
+			code := authorizeCode(t, svr, url.Values{
+				"client_id":    []string{"test-client"},
+				"redirect_uri": []string{"http://localhost/callback"},
+			})
+
+			buf, _ := json.Marshal(map[string]string{
+				"client_id":     "test-client",
+				"client_secret": tt.secret,
+				"code":          code,
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/login/oauth/access_token", bytes.NewReader(buf))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := doRequest(t, svr, req)
+
+			if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, tt.wantType) {
+				t.Errorf("access_token Content-Type = %q, expected = %q", ct, tt.wantType)
+			}
+
+			if body := w.Body.String(); !strings.Contains(body, tt.wantContain) {
+				t.Errorf("access_token body = %q, expected to contain %q", body, tt.wantContain)
+			}
+		})
+	}
+}
+
+func TestServer_AccessTokenInputStyles(t *testing.T) {
+	tests := []struct {
+		name       string
+		build      func(code string) *http.Request
+		wantStatus int
+		wantError  string
+	}{
+		{
+			name: "form body",
+			build: func(code string) *http.Request {
+				body := url.Values{"client_id": {"test-client"}, "client_secret": {"secret"}, "code": {code}}
+				req := httptest.NewRequest(
+					http.MethodPost, "/login/oauth/access_token", strings.NewReader(body.Encode()),
+				)
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				return req
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "json body",
+			build: func(code string) *http.Request {
+				body := `{"client_id":"test-client","client_secret":"secret","code":"` + code + `"}`
+				req := httptest.NewRequest(http.MethodPost, "/login/oauth/access_token", strings.NewReader(body))
+				req.Header.Set("Content-Type", "application/json; charset=utf-8")
+				return req
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "query string",
+			build: func(code string) *http.Request {
+				query := url.Values{"client_id": {"test-client"}, "client_secret": {"secret"}, "code": {code}}
+				return httptest.NewRequest(http.MethodPost, "/login/oauth/access_token?"+query.Encode(), nil)
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "basic auth",
+			build: func(code string) *http.Request {
+				body := url.Values{"code": {code}}
+				req := httptest.NewRequest(
+					http.MethodPost, "/login/oauth/access_token", strings.NewReader(body.Encode()),
+				)
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				req.SetBasicAuth("test-client", "secret")
+				return req
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "basic auth wrong secret",
+			build: func(code string) *http.Request {
+				body := url.Values{"code": {code}}
+				req := httptest.NewRequest(
+					http.MethodPost, "/login/oauth/access_token", strings.NewReader(body.Encode()),
+				)
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				req.SetBasicAuth("test-client", "wrong")
+				return req
+			},
+			wantStatus: http.StatusOK,
+			wantError:  "incorrect_client_credentials",
+		},
+		{
+			name: "malformed json",
+			build: func(_ string) *http.Request {
+				req := httptest.NewRequest(http.MethodPost, "/login/oauth/access_token", strings.NewReader("{"))
+				req.Header.Set("Content-Type", "application/json")
+				return req
+			},
+			wantStatus: http.StatusBadRequest,
+			wantError:  "invalid_request",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svr := newTestServer(t, nil)
+
+			code := authorizeCode(t, svr, url.Values{
+				"client_id":    []string{"test-client"},
+				"redirect_uri": []string{"http://localhost/callback"},
+			})
+
+			w := doRequest(t, svr, tt.build(code))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("access_token status = %d, expected = %d", w.Code, tt.wantStatus)
+			}
+
+			resp, err := url.ParseQuery(w.Body.String())
+			if err != nil {
+				t.Fatalf("access_token body parse error = %v", err)
+			}
+
+			if v := resp.Get("error"); v != tt.wantError {
+				t.Errorf("access_token error = %q, expected = %q", v, tt.wantError)
+			}
+
+			if v := resp.Get("access_token"); (v != "") != (tt.wantError == "") {
+				t.Errorf("access_token access_token = %q, expected token = %t", v, tt.wantError == "")
+			}
+		})
+	}
+}
+
+func TestServer_AuthorizeErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		clientID   string
+		wantStatus int
+		wantError  string
+	}{
+		{"unknown client", "unknown-client", http.StatusNotFound, ""},
+		{"suspended application", "suspended-client", http.StatusFound, mockghauth.OAuthErrorApplicationSuspended},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svr := newTestServer(t, nil)
+			svr.RegisterClient(&mockghauth.Client{
+				ID:          "suspended-client",
+				Secret:      "secret",
+				CallbackURL: "http://localhost/callback",
+				Suspended:   true,
+			})
+
+			query := url.Values{"client_id": []string{tt.clientID}, "state": []string{"st"}}
+			req := httptest.NewRequest(http.MethodGet, "/login/oauth/authorize?"+query.Encode(), nil)
+			w := doRequest(t, svr, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("authorize status = %d, expected = %d", w.Code, tt.wantStatus)
+			}
+
+			if tt.wantError == "" {
+				return
+			}
+
+			loc, err := url.Parse(w.Header().Get("Location"))
+			if err != nil {
+				t.Fatalf("authorize Location parse error = %v", err)
+			}
+
+			params := loc.Query()
+			if v := params.Get("error"); v != tt.wantError {
+				t.Errorf("authorize error = %q, expected = %q", v, tt.wantError)
+			}
+
+			expect := mockghauth.NewOAuthError(tt.wantError)
+			if v := params.Get("error_description"); v != expect.ErrorDescription {
+				t.Errorf("authorize error_description = %q, expected = %q", v, expect.ErrorDescription)
+			}
+
+			if v := params.Get("error_uri"); v != expect.ErrorURI {
+				t.Errorf("authorize error_uri = %q, expected = %q", v, expect.ErrorURI)
+			}
+		})
+	}
+}
+
+func TestServer_PKCE(t *testing.T) {
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	s256 := mockghauth.PKCEChallenge(verifier, "S256")
+
+	tests := []struct {
+		name           string
+		clientID       string
+		challenge      string
+		method         string
+		verifier       string
+		wantAuthError  string
+		wantTokenError string
+	}{
+		{"no pkce", "test-client", "", "", "", "", ""},
+		{"S256", "test-client", s256, "S256", verifier, "", ""},
+		{"plain by default", "test-client", verifier, "", verifier, "", ""},
+		{"wrong verifier", "test-client", s256, "S256", strings.Repeat("a", 43), "", "bad_verification_code"},
+		{"missing verifier", "test-client", s256, "S256", "", "", "bad_verification_code"},
+		{"unsupported method", "test-client", verifier, "S512", "", "invalid_request", ""},
+		{"required and missing", "pkce-client", "", "", "", "invalid_request", ""},
+		{"required and present", "pkce-client", s256, "S256", verifier, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svr := newTestServer(t, nil)
+			svr.RegisterClient(&mockghauth.Client{ID: "pkce-client", Secret: "secret", RequirePKCE: true})
+
+			query := url.Values{
+				"client_id":    []string{tt.clientID},
+				"redirect_uri": []string{"http://localhost/callback"},
+			}
+			if tt.challenge != "" {
+				query.Set("code_challenge", tt.challenge)
+			}
+			if tt.method != "" {
+				query.Set("code_challenge_method", tt.method)
+			}
+
+			params := authorizeRedirect(t, svr, query)
+			if v := params.Get("error"); v != tt.wantAuthError {
+				t.Fatalf("authorize error = %q, expected = %q", v, tt.wantAuthError)
+			}
+
+			if tt.wantAuthError != "" {
+				return
+			}
+
+			resp := accessTokenJSON(t, svr, map[string]string{
+				"client_id":     tt.clientID,
+				"client_secret": "secret",
+				"code":          params.Get("code"),
+				"code_verifier": tt.verifier,
+			})
+
+			if v, _ := resp["error"].(string); v != tt.wantTokenError {
+				t.Errorf("access_token error = %q, expected = %q", v, tt.wantTokenError)
+			}
+		})
+	}
+}
+
+func TestServer_Scopes(t *testing.T) {
+	tests := []struct {
+		name       string
+		scope      string
+		wantScope  string
+		wantHeader string
+	}{
+		{"no scope", "", "", ""},
+		{"single scope", "user", "user", "user"},
+		{"normalised scopes", "repo repo:status gist", "gist,repo", "gist, repo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svr := newTestServer(t, nil)
+
+			resp := issueToken(t, svr, url.Values{"scope": []string{tt.scope}})
+
+			if v, _ := resp["scope"].(string); v != tt.wantScope {
+				t.Errorf("access_token scope = %q, expected = %q", v, tt.wantScope)
+			}
+
+			token, _ := resp["access_token"].(string)
+			w := apiRequest(t, svr, "/api/v3/user", token)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("/api/v3/user status = %d, expected = %d", w.Code, http.StatusOK)
+			}
+
+			if v := w.Header().Get("X-OAuth-Scopes"); v != tt.wantHeader {
+				t.Errorf("/api/v3/user X-OAuth-Scopes = %q, expected = %q", v, tt.wantHeader)
+			}
+
+			if _, ok := w.Header()["X-Accepted-Oauth-Scopes"]; !ok {
+				t.Errorf("/api/v3/user X-Accepted-OAuth-Scopes header missing")
+			}
+		})
+	}
+}
+
+func TestServer_ScopeEnforcement(t *testing.T) {
+	tests := []struct {
+		name        string
+		scope       string
+		path        string
+		wantStatus  int
+		wantFields  []string
+		wantAbsent  []string
+		wantAccepts string
+		wantMessage string
+	}{
+		{
+			name:       "user without scope is public only",
+			path:       "/api/v3/user",
+			wantStatus: http.StatusOK,
+			wantFields: []string{"login", "email"},
+			wantAbsent: []string{"plan", "private_gists"},
+		},
+		{
+			name:       "user with read:user includes private fields",
+			scope:      "read:user",
+			path:       "/api/v3/user",
+			wantStatus: http.StatusOK,
+			wantFields: []string{"login", "plan", "private_gists"},
+		},
+		{
+			name:       "user with user:email includes email",
+			scope:      "user:email",
+			path:       "/api/v3/user",
+			wantStatus: http.StatusOK,
+			wantFields: []string{"login", "email"},
+			wantAbsent: []string{"plan"},
+		},
+		{
+			name:        "orgs without read:org or user is forbidden",
+			scope:       "repo",
+			path:        "/api/v3/user/orgs",
+			wantStatus:  http.StatusForbidden,
+			wantFields:  []string{"message", "documentation_url"},
+			wantAccepts: "read:org, user",
+			wantMessage: "Your token has not been granted the required scopes to access this resource. It requires " +
+				"one of the following scopes: ['read:org', 'user'], but your token has only been granted the: " +
+				"['repo'] scopes.",
+		},
+		{
+			name:        "orgs with admin:org is allowed",
+			scope:       "admin:org",
+			path:        "/api/v3/user/orgs",
+			wantStatus:  http.StatusOK,
+			wantAccepts: "read:org, user",
+		},
+		{
+			name:        "orgs with user is allowed",
+			scope:       "user",
+			path:        "/api/v3/user/orgs",
+			wantStatus:  http.StatusOK,
+			wantAccepts: "read:org, user",
+		},
+		{
+			name:        "teams with user is allowed",
+			scope:       "user",
+			path:        "/api/v3/user/teams",
+			wantStatus:  http.StatusOK,
+			wantAccepts: "read:org, repo, user",
+		},
+		{
+			name:        "teams with repo is allowed",
+			scope:       "repo",
+			path:        "/api/v3/user/teams",
+			wantStatus:  http.StatusOK,
+			wantAccepts: "read:org, repo, user",
+		},
+		{
+			name:        "teams without read:org, repo or user is forbidden",
+			scope:       "gist",
+			path:        "/api/v3/user/teams",
+			wantStatus:  http.StatusForbidden,
+			wantAccepts: "read:org, repo, user",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svr := newTestServer(t, nil)
+
+			resp := issueToken(t, svr, url.Values{"scope": []string{tt.scope}})
+			token, _ := resp["access_token"].(string)
+
+			w := apiRequest(t, svr, tt.path, token)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("%s status = %d, expected = %d", tt.path, w.Code, tt.wantStatus)
+			}
+
+			if v := w.Header().Get("X-Accepted-OAuth-Scopes"); v != tt.wantAccepts {
+				t.Errorf("%s X-Accepted-OAuth-Scopes = %q, expected = %q", tt.path, v, tt.wantAccepts)
+			}
+
+			if len(tt.wantFields) == 0 && len(tt.wantAbsent) == 0 {
+				return
+			}
+
+			body := map[string]any{}
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatalf("%s decode error = %v", tt.path, err)
+			}
+
+			for _, field := range tt.wantFields {
+				if v, ok := body[field]; !ok || v == nil {
+					t.Errorf("%s field %q expected to be set", tt.path, field)
+				}
+			}
+
+			for _, field := range tt.wantAbsent {
+				if v, ok := body[field]; ok && v != nil {
+					t.Errorf("%s field %q expected to be absent, received = %v", tt.path, field, v)
+				}
+			}
+
+			if v, _ := body["message"].(string); tt.wantMessage != "" && v != tt.wantMessage {
+				t.Errorf("%s message = %q, expected = %q", tt.path, v, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestServer_TokensBoundToUser(t *testing.T) {
+	svr := newTestServer(t, map[string]any{"load.users-file": "../testdata/users.json"})
+
+	for _, login := range []string{"octocat", "hubot", "outsider"} {
+		t.Run(login, func(t *testing.T) {
+			svr.RegisterClient(&mockghauth.Client{ID: login + "-client", Secret: "secret", User: login})
+
+			code := authorizeCode(t, svr, url.Values{
+				"client_id":    []string{login + "-client"},
+				"redirect_uri": []string{"http://localhost/callback"},
+			})
+
+			resp := accessTokenJSON(t, svr, map[string]string{
+				"client_id":     login + "-client",
+				"client_secret": "secret",
+				"code":          code,
+			})
+			token, _ := resp["access_token"].(string)
+
+			w := apiRequest(t, svr, "/api/v3/user", token)
+			if w.Code != http.StatusOK {
+				t.Fatalf("/api/v3/user status = %d, expected = %d", w.Code, http.StatusOK)
+			}
+
+			user := map[string]any{}
+			if err := json.NewDecoder(w.Body).Decode(&user); err != nil {
+				t.Fatalf("/api/v3/user decode error = %v", err)
+			}
+
+			if v, _ := user["login"].(string); v != login {
+				t.Errorf("/api/v3/user login = %q, expected = %q", v, login)
+			}
+
+			if v, _ := user["url"].(string); v != "http://localhost:8080/api/v3/users/"+login {
+				t.Errorf("/api/v3/user url = %q, expected to point at %s", v, login)
+			}
+		})
+	}
+}
+
+func TestServer_TokensBoundToRequestedLogin(t *testing.T) {
+	svr := newTestServer(t, map[string]any{"load.users-file": "../testdata/users.json"})
+
+	tests := []struct {
+		name     string
+		login    string
+		wantUser string
+	}{
+		{"no login uses default user", "", "octocat"},
+		{"login hubot", "hubot", "hubot"},
+		{"login outsider", "outsider", "outsider"},
+		{"login is case-insensitive", "HUBOT", "hubot"},
+		{"unknown login uses default user", "nobody", "octocat"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := url.Values{}
+			if tt.login != "" {
+				query.Set("login", tt.login)
+			}
+
+			token, _ := issueToken(t, svr, query)["access_token"].(string)
+
+			user := map[string]any{}
+			if err := json.NewDecoder(apiRequest(t, svr, "/api/v3/user", token).Body).Decode(&user); err != nil {
+				t.Fatalf("/api/v3/user decode error = %v", err)
+			}
+
+			if v, _ := user["login"].(string); v != tt.wantUser {
+				t.Errorf("/api/v3/user login = %q, expected = %q", v, tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestServer_InteractiveAuthorize(t *testing.T) {
+	svr := newTestServer(t, map[string]any{
+		"oauth.interactive": true,
+		"load.users-file":   "../testdata/users.json",
+	})
+	svr.RegisterClient(&mockghauth.Client{
+		ID:          "app-client",
+		Secret:      "secret",
+		Name:        "Example App",
+		CallbackURL: "http://localhost/callback",
+	})
+
+	query := url.Values{
+		"client_id": []string{"app-client"},
+		"scope":     []string{"read:org user"},
+		"state":     []string{"st"},
+	}
+	req := httptest.NewRequest(http.MethodGet, "/login/oauth/authorize?"+query.Encode(), nil)
+	w := doRequest(t, svr, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("authorize status = %d, expected = %d", w.Code, http.StatusOK)
+	}
+
+	page := w.Body.String()
+	wantContent := []string{
+		"Example App", "read:org", "user", "hubot", "octocat", "outsider", `name="state" value="st"`,
+	}
+	for _, want := range wantContent {
+		if !strings.Contains(page, want) {
+			t.Errorf("authorize page expected to contain %q", want)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		action    string
+		login     string
+		wantError string
+	}{
+		{"authorize as hubot", "authorize", "hubot", ""},
+		{"cancel", "cancel", "hubot", mockghauth.OAuthErrorAccessDenied},
+		{"unknown user", "authorize", "nobody", mockghauth.OAuthErrorAccessDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{
+				"client_id": []string{"app-client"},
+				"scope":     []string{"read:org user"},
+				"state":     []string{"st"},
+				"login":     []string{tt.login},
+				tt.action:   []string{"1"},
+			}
+			req := httptest.NewRequest(http.MethodPost, "/login/oauth/authorize", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := doRequest(t, svr, req)
+
+			if w.Code != http.StatusFound {
+				t.Fatalf("authorize submit status = %d, expected = %d", w.Code, http.StatusFound)
+			}
+
+			loc, err := url.Parse(w.Header().Get("Location"))
+			if err != nil {
+				t.Fatalf("authorize submit Location parse error = %v", err)
+			}
+
+			params := loc.Query()
+			if v := params.Get("error"); v != tt.wantError {
+				t.Fatalf("authorize submit error = %q, expected = %q", v, tt.wantError)
+			}
+
+			if v := params.Get("state"); v != "st" {
+				t.Errorf("authorize submit state = %q, expected = %q", v, "st")
+			}
+
+			if tt.wantError != "" {
+				return
+			}
+
+			resp := accessTokenJSON(t, svr, map[string]string{
+				"client_id":     "app-client",
+				"client_secret": "secret",
+				"code":          params.Get("code"),
+			})
+			token, _ := resp["access_token"].(string)
+
+			user := map[string]any{}
+			if err = json.NewDecoder(apiRequest(t, svr, "/api/v3/user", token).Body).Decode(&user); err != nil {
+				t.Fatalf("/api/v3/user decode error = %v", err)
+			}
+
+			if v, _ := user["login"].(string); v != tt.login {
+				t.Errorf("/api/v3/user login = %q, expected = %q", v, tt.login)
+			}
+		})
+	}
+}
+
+func TestServer_AuthorizeSelectAccountChoice(t *testing.T) {
+	svr := newTestServer(t, map[string]any{
+		"oauth.interactive": true,
+		"load.users-file":   "../testdata/users.json",
+	})
+
+	query := url.Values{
+		"client_id":    []string{"test-client"},
+		"redirect_uri": []string{"http://localhost/callback"},
+		"login":        []string{"hubot"},
+		"prompt":       []string{"select_account"},
+	}
+	req := httptest.NewRequest(http.MethodGet, "/login/oauth/authorize?"+query.Encode(), nil)
+	w := doRequest(t, svr, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("authorize status = %d, expected = %d", w.Code, http.StatusOK)
+	}
+
+	page := w.Body.String()
+	if !strings.Contains(page, `value="hubot" checked`) {
+		t.Errorf("authorize page expected to preselect the suggested login")
+	}
+
+	// Submit the page as a browser would, the hidden params in page order
+	// followed by the account picker, choosing a user other than the
+	// suggested login.
+	hidden := regexp.MustCompile(`type="hidden" name="([^"]+)" value="([^"]*)"`)
+	body := []string{}
+	for _, m := range hidden.FindAllStringSubmatch(page, -1) {
+		body = append(body, url.QueryEscape(m[1])+"="+url.QueryEscape(html.UnescapeString(m[2])))
+	}
+	body = append(body, "login=outsider", "authorize=1")
+
+	req = httptest.NewRequest(http.MethodPost, "/login/oauth/authorize", strings.NewReader(strings.Join(body, "&")))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = doRequest(t, svr, req)
+
+	loc, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("authorize submit Location parse error = %v", err)
+	}
+
+	code, ok := svr.Code(loc.Query().Get("code"))
+	if !ok {
+		t.Fatalf("authorize submit code expected to exist, Location = %s", loc)
+	}
+
+	if code.User != "outsider" {
+		t.Errorf("authorize submit code user = %q, expected = %q", code.User, "outsider")
+	}
+}
+
+func TestServer_AuthorizeLoginPromptAllowSignup(t *testing.T) {
+	tests := []struct {
+		name            string
+		interactive     bool
+		query           url.Values
+		wantPage        bool
+		wantPicker      bool
+		wantUser        string
+		wantAllowSignup bool
+	}{
+		{
+			name:            "login picks user",
+			query:           url.Values{"login": []string{"hubot"}},
+			wantUser:        "hubot",
+			wantAllowSignup: true,
+		},
+		{
+			name:            "unknown login falls back to default user",
+			query:           url.Values{"login": []string{"nobody"}},
+			wantUser:        "octocat",
+			wantAllowSignup: true,
+		},
+		{
+			name:            "allow_signup=false is recorded",
+			query:           url.Values{"login": []string{"outsider"}, "allow_signup": []string{"false"}},
+			wantUser:        "outsider",
+			wantAllowSignup: false,
+		},
+		{
+			name:        "interactive login asks consent for that user",
+			interactive: true,
+			query:       url.Values{"login": []string{"hubot"}},
+			wantPage:    true,
+			wantUser:    "hubot",
+		},
+		{
+			name:        "interactive select_account forces picker",
+			interactive: true,
+			query:       url.Values{"login": []string{"hubot"}, "prompt": []string{"select_account"}},
+			wantPage:    true,
+			wantPicker:  true,
+			wantUser:    "hubot",
+		},
+		{
+			name:        "interactive without login shows picker",
+			interactive: true,
+			query:       url.Values{},
+			wantPage:    true,
+			wantPicker:  true,
+			wantUser:    "octocat",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svr := newTestServer(t, map[string]any{
+				"oauth.interactive": tt.interactive,
+				"load.users-file":   "../testdata/users.json",
+			})
+
+			tt.query.Set("client_id", "test-client")
+			tt.query.Set("redirect_uri", "http://localhost/callback")
+
+			req := httptest.NewRequest(http.MethodGet, "/login/oauth/authorize?"+tt.query.Encode(), nil)
+			w := doRequest(t, svr, req)
+
+			if tt.wantPage {
+				body := w.Body.String()
+				if w.Code != http.StatusOK || !strings.Contains(body, `value="`+tt.wantUser+`" checked`) {
+					t.Errorf("authorize status = %d, expected page with %q selected", w.Code, tt.wantUser)
+				}
+
+				if picker := strings.Count(body, `name="login"`) > 1; picker != tt.wantPicker {
+					t.Errorf("authorize account picker = %t, expected = %t", picker, tt.wantPicker)
+				}
+				return
+			}
+
+			loc, err := url.Parse(w.Header().Get("Location"))
+			if err != nil {
+				t.Fatalf("authorize Location parse error = %v", err)
+			}
+
+			code, ok := svr.Code(loc.Query().Get("code"))
+			if !ok {
+				t.Fatalf("authorize code expected to exist, Location = %s", loc)
+			}
+
+			if code.User != tt.wantUser {
+				t.Errorf("authorize code user = %q, expected = %q", code.User, tt.wantUser)
+			}
+
+			if code.AllowSignup != tt.wantAllowSignup {
+				t.Errorf("authorize code allow_signup = %t, expected = %t", code.AllowSignup, tt.wantAllowSignup)
+			}
+
+			w = apiRequest(t, svr, "/_mock/codes/"+loc.Query().Get("code"), "")
+			if !strings.Contains(w.Body.String(), `"user":"`+tt.wantUser+`"`) {
+				t.Errorf("/_mock/codes body = %s, expected user %q", w.Body.String(), tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestServer_TokenExpiry(t *testing.T) {
+	tests := []struct {
+		name        string
+		token       string
+		wantStatus  int
+		wantMessage string
+	}{
+		{"expired token from file", "test-code", http.StatusUnauthorized, "Bad credentials"},
+		{"unknown token", "gho_unknown", http.StatusUnauthorized, "Bad credentials"},
+		{"missing token", "", http.StatusUnauthorized, "Must authenticate to access this API."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svr := newTestServer(t, map[string]any{"load.tokens-file": "../testdata/tokens.json"})
+
+			w := apiRequest(t, svr, "/api/v3/user", tt.token)
+			if w.Code != tt.wantStatus {
+				t.Errorf("/api/v3/user status = %d, expected = %d", w.Code, tt.wantStatus)
+			}
+
+			body := map[string]any{}
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatalf("/api/v3/user decode error = %v", err)
+			}
+
+			if v, _ := body["message"].(string); v != tt.wantMessage {
+				t.Errorf("/api/v3/user message = %q, expected = %q", v, tt.wantMessage)
+			}
+		})
+	}
+
+	t.Run("token expires after issue", func(t *testing.T) {
+		svr := newTestServer(t, map[string]any{"oauth.token-expire": "50ms"})
+
+		resp := issueToken(t, svr, url.Values{})
+		token, _ := resp["access_token"].(string)
+
+		if w := apiRequest(t, svr, "/api/v3/user", token); w.Code != http.StatusOK {
+			t.Fatalf("/api/v3/user status = %d, expected = %d", w.Code, http.StatusOK)
+		}
+
+		time.Sleep(100 * time.Millisecond)
+
+		if w := apiRequest(t, svr, "/api/v3/user", token); w.Code != http.StatusUnauthorized {
+			t.Errorf("/api/v3/user status = %d, expected = %d", w.Code, http.StatusUnauthorized)
+		}
+	})
+}
+
+func TestServer_RefreshToken(t *testing.T) {
+	svr := newTestServer(t, nil)
+	svr.RegisterClient(&mockghauth.Client{
+		ID: "app-client", Secret: "secret", CallbackURL: "http://localhost/callback", ExpiringTokens: true,
+	})
+
+	query := url.Values{"client_id": {"app-client"}, "scope": {"user"}}
+	resp := accessTokenJSON(t, svr, map[string]string{
+		"client_id":     "app-client",
+		"client_secret": "secret",
+		"code":          authorizeCode(t, svr, query),
+	})
+
+	accessToken, _ := resp["access_token"].(string)
+	refreshToken, _ := resp["refresh_token"].(string)
+
+	if !strings.HasPrefix(accessToken, "ghu_") {
+		t.Errorf("access_token = %q, expected ghu_ prefix", accessToken)
+	}
+
+	if !strings.HasPrefix(refreshToken, "ghr_") {
+		t.Errorf("refresh_token = %q, expected ghr_ prefix", refreshToken)
+	}
+
+	if v, _ := resp["expires_in"].(float64); v != 28800 {
+		t.Errorf("expires_in = %v, expected = %v", v, 28800)
+	}
+
+	if v, _ := resp["refresh_token_expires_in"].(float64); v != 15897600 {
+		t.Errorf("refresh_token_expires_in = %v, expected = %v", v, 15897600)
+	}
+
+	if w := apiRequest(t, svr, "/api/v3/user", refreshToken); w.Code != http.StatusUnauthorized {
+		t.Errorf("refresh token API status = %d, expected = %d", w.Code, http.StatusUnauthorized)
+	}
+
+	refreshed := accessTokenJSON(t, svr, map[string]string{
+		"client_id":     "app-client",
+		"client_secret": "secret",
+		"grant_type":    "refresh_token",
+		"refresh_token": refreshToken,
+	})
+
+	newAccessToken, _ := refreshed["access_token"].(string)
+	newRefreshToken, _ := refreshed["refresh_token"].(string)
+
+	if !strings.HasPrefix(newAccessToken, "ghu_") || newAccessToken == accessToken {
+		t.Errorf("refreshed access_token = %q, expected a new ghu_ token", newAccessToken)
+	}
+
+	if !strings.HasPrefix(newRefreshToken, "ghr_") || newRefreshToken == refreshToken {
+		t.Errorf("refreshed refresh_token = %q, expected a new ghr_ token", newRefreshToken)
+	}
+
+	if v, _ := refreshed["scope"].(string); v != "user" {
+		t.Errorf("refreshed scope = %q, expected = %q", v, "user")
+	}
+
+	if w := apiRequest(t, svr, "/api/v3/user", newAccessToken); w.Code != http.StatusOK {
+		t.Errorf("refreshed token API status = %d, expected = %d", w.Code, http.StatusOK)
+	}
+
+	tests := []struct {
+		name      string
+		body      map[string]string
+		wantError string
+	}{
+		{"reused refresh token", map[string]string{
+			"client_id": "app-client", "client_secret": "secret",
+			"grant_type": "refresh_token", "refresh_token": refreshToken,
+		}, "bad_refresh_token"},
+		{"unknown refresh token", map[string]string{
+			"client_id": "app-client", "client_secret": "secret",
+			"grant_type": "refresh_token", "refresh_token": "ghr_unknown",
+		}, "bad_refresh_token"},
+		{"refresh token for another client", map[string]string{
+			"client_id": "test-client", "client_secret": "secret",
+			"grant_type": "refresh_token", "refresh_token": newRefreshToken,
+		}, "bad_refresh_token"},
+		{"unsupported grant type", map[string]string{
+			"client_id": "app-client", "client_secret": "secret",
+			"grant_type": "password",
+		}, "unsupported_grant_type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := accessTokenJSON(t, svr, tt.body)
+			if v, _ := got["error"].(string); v != tt.wantError {
+				t.Errorf("error = %q, expected = %q", v, tt.wantError)
+			}
+		})
+	}
+
+	t.Run("refresh token survives a rejected client", func(t *testing.T) {
+		got := accessTokenJSON(t, svr, map[string]string{
+			"client_id": "app-client", "client_secret": "secret",
+			"grant_type": "refresh_token", "refresh_token": newRefreshToken,
+		})
+		if v, _ := got["refresh_token"].(string); !strings.HasPrefix(v, "ghr_") {
+			t.Errorf("refresh_token = %q, expected ghr_ prefix", v)
+		}
+	})
+}
+
+func TestServer_RefreshTokenFormResponse(t *testing.T) {
+	svr := newTestServer(t, nil)
+	svr.RegisterClient(&mockghauth.Client{
+		ID: "app-client", Secret: "secret", CallbackURL: "http://localhost/callback", ExpiringTokens: true,
+	})
+
+	form := url.Values{
+		"client_id":     {"app-client"},
+		"client_secret": {"secret"},
+		"code":          {authorizeCode(t, svr, url.Values{"client_id": {"app-client"}})},
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/login/oauth/access_token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := doRequest(t, svr, req)
+
+	values, err := url.ParseQuery(w.Body.String())
+	if err != nil {
+		t.Fatalf("url.ParseQuery() error = %v", err)
+	}
+
+	wantValues := map[string]string{
+		"expires_in":               "28800",
+		"refresh_token_expires_in": "15897600",
+		"token_type":               "bearer",
+	}
+	for k, want := range wantValues {
+		if got := values.Get(k); got != want {
+			t.Errorf("%s = %q, expected = %q", k, got, want)
+		}
+	}
+
+	if got := values.Get("refresh_token"); !strings.HasPrefix(got, "ghr_") {
+		t.Errorf("refresh_token = %q, expected ghr_ prefix", got)
+	}
+}
+
+func deviceCode(t *testing.T, svr *mockghauth.Server, scope string) map[string]any {
+	t.Helper()
+
+	return postOAuthJSON(t, svr, "/login/device/code", map[string]string{"client_id": "test-client", "scope": scope})
+}
+
+func pollDeviceCode(t *testing.T, svr *mockghauth.Server, code string) map[string]any {
+	t.Helper()
+
+	return accessTokenJSON(t, svr, map[string]string{
+		"client_id":   "test-client",
+		"device_code": code,
+		"grant_type":  "urn:ietf:params:oauth:grant-type:device_code",
+	})
+}
+
+func TestServer_DeviceFlow(t *testing.T) {
+	svr := newTestServer(t, nil)
+
+	resp := deviceCode(t, svr, "read:org")
+
+	code, _ := resp["device_code"].(string)
+	userCode, _ := resp["user_code"].(string)
+
+	if v, _ := resp["verification_uri"].(string); v != "http://localhost:8080/login/device" {
+		t.Errorf("verification_uri = %q, expected = %q", v, "http://localhost:8080/login/device")
+	}
+
+	if v, _ := resp["expires_in"].(float64); v != 900 {
+		t.Errorf("expires_in = %v, expected = %v", v, 900)
+	}
+
+	if v, _ := resp["interval"].(float64); v != 5 {
+		t.Errorf("interval = %v, expected = %v", v, 5)
+	}
+
+	if v, _ := pollDeviceCode(t, svr, code)["error"].(string); v != "authorization_pending" {
+		t.Errorf("first poll error = %q, expected = %q", v, "authorization_pending")
+	}
+
+	slow := pollDeviceCode(t, svr, code)
+	if v, _ := slow["error"].(string); v != "slow_down" {
+		t.Errorf("second poll error = %q, expected = %q", v, "slow_down")
+	}
+
+	if v, _ := slow["interval"].(float64); v != 10 {
+		t.Errorf("slow_down interval = %v, expected = %v", v, 10)
+	}
+
+	if err := svr.ApproveDeviceCode(userCode, "nobody"); !errors.Is(err, mockghauth.ErrUserNotFound) {
+		t.Errorf("ApproveDeviceCode() error: expected = %v, received = %v", mockghauth.ErrUserNotFound, err)
+	}
+
+	if err := svr.ApproveDeviceCode(userCode, "octocat"); err != nil {
+		t.Fatalf("ApproveDeviceCode() error = %v", err)
+	}
+
+	token := pollDeviceCode(t, svr, code)
+	if v, _ := token["scope"].(string); v != "read:org" {
+		t.Errorf("token scope = %q, expected = %q", v, "read:org")
+	}
+
+	accessToken, _ := token["access_token"].(string)
+	if w := apiRequest(t, svr, "/api/v3/user/orgs", accessToken); w.Code != http.StatusOK {
+		t.Errorf("/api/v3/user/orgs status = %d, expected = %d", w.Code, http.StatusOK)
+	}
+
+	if v, _ := pollDeviceCode(t, svr, code)["error"].(string); v != "incorrect_device_code" {
+		t.Errorf("reused device code error = %q, expected = %q", v, "incorrect_device_code")
+	}
+}
+
+func TestServer_DeviceFlowErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		settings  map[string]any
+		resolve   func(svr *mockghauth.Server, userCode string) *httptest.ResponseRecorder
+		wantError string
+	}{
+		{"denied by admin hook", nil, func(svr *mockghauth.Server, userCode string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, "/_mock/device/"+userCode+"/deny", nil)
+			return doRequest(t, svr, req)
+		}, "access_denied"},
+		{"denied on the device page", nil, func(svr *mockghauth.Server, userCode string) *httptest.ResponseRecorder {
+			form := url.Values{"user_code": {userCode}, "cancel": {"1"}}
+			req := httptest.NewRequest(http.MethodPost, "/login/device", strings.NewReader(form.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			return doRequest(t, svr, req)
+		}, "access_denied"},
+		{"expired", map[string]any{"oauth.device-code-expire": "1ms"}, func(
+			_ *mockghauth.Server, _ string,
+		) *httptest.ResponseRecorder {
+			time.Sleep(5 * time.Millisecond)
+			return nil
+		}, "expired_token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svr := newTestServer(t, tt.settings)
+
+			resp := deviceCode(t, svr, "")
+			code, _ := resp["device_code"].(string)
+			userCode, _ := resp["user_code"].(string)
+
+			if w := tt.resolve(svr, userCode); w != nil && w.Code >= http.StatusBadRequest {
+				t.Fatalf("resolve status = %d, expected success", w.Code)
+			}
+
+			if v, _ := pollDeviceCode(t, svr, code)["error"].(string); v != tt.wantError {
+				t.Errorf("poll error = %q, expected = %q", v, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestServer_DeviceActivationPage(t *testing.T) {
+	svr := newTestServer(t, map[string]any{"load.users-file": "../testdata/users.json"})
+
+	resp := deviceCode(t, svr, "user")
+	code, _ := resp["device_code"].(string)
+	userCode, _ := resp["user_code"].(string)
+
+	w := doRequest(t, svr, httptest.NewRequest(http.MethodGet, "/login/device", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `name="user_code"`) {
+		t.Errorf("/login/device status = %d, expected the user code form", w.Code)
+	}
+
+	w = doRequest(t, svr, httptest.NewRequest(http.MethodGet, "/login/device?user_code=ZZZZ-ZZZZ", nil))
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), `id="error"`) {
+		t.Errorf("/login/device unknown code status = %d, expected = %d with an error", w.Code, http.StatusNotFound)
+	}
+
+	typed := strings.ToLower(userCode)
+	w = doRequest(t, svr, httptest.NewRequest(http.MethodGet, "/login/device?user_code="+typed, nil))
+
+	page := w.Body.String()
+	for _, want := range []string{"test-client", "hubot", "<code>user</code>", `value="` + userCode + `"`} {
+		if !strings.Contains(page, want) {
+			t.Errorf("/login/device page expected to contain %q", want)
+		}
+	}
+
+	form := url.Values{"user_code": {userCode}, "login": {"hubot"}, "authorize": {"1"}}
+	req := httptest.NewRequest(http.MethodPost, "/login/device", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if w = doRequest(t, svr, req); !strings.Contains(w.Body.String(), "all set") {
+		t.Errorf("/login/device submit status = %d, expected the approved page", w.Code)
+	}
+
+	accessToken, _ := pollDeviceCode(t, svr, code)["access_token"].(string)
+
+	w = apiRequest(t, svr, "/api/v3/user", accessToken)
+
+	user := map[string]any{}
+	if err := json.NewDecoder(w.Body).Decode(&user); err != nil {
+		t.Fatalf("/api/v3/user decode error = %v", err)
+	}
+
+	if v, _ := user["login"].(string); v != "hubot" {
+		t.Errorf("/api/v3/user login = %q, expected = %q", v, "hubot")
+	}
+}
+
+func TestServer_IssuedTokenFormats(t *testing.T) {
+	svr := newTestServer(t, nil)
+	svr.RegisterClient(&mockghauth.Client{
+		ID: "app-client", Secret: "secret", CallbackURL: "http://localhost/callback", ExpiringTokens: true,
+	})
+
+	oauthToken, _ := issueToken(t, svr, url.Values{})["access_token"].(string)
+	appResp := accessTokenJSON(t, svr, map[string]string{
+		"client_id":     "app-client",
+		"client_secret": "secret",
+		"code":          authorizeCode(t, svr, url.Values{"client_id": {"app-client"}}),
+	})
+	userToken, _ := appResp["access_token"].(string)
+	refreshToken, _ := appResp["refresh_token"].(string)
+
+	classic, err := svr.IssuePersonalAccessToken("octocat", mockghauth.TokenKindPersonal, mockghauth.Scopes{"repo"})
+	if err != nil {
+		t.Fatalf("IssuePersonalAccessToken() error = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/_mock/tokens",
+		strings.NewReader(`{"kind":"fine_grained","user":"octocat"}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := doRequest(t, svr, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("/_mock/tokens status = %d, expected = %d", w.Code, http.StatusCreated)
+	}
+
+	fineGrained := map[string]string{}
+	if err = json.NewDecoder(w.Body).Decode(&fineGrained); err != nil {
+		t.Fatalf("/_mock/tokens decode error = %v", err)
+	}
+
+	fineGrainedToken := fineGrained["token"]
+
+	tests := []struct {
+		name   string
+		token  string
+		prefix string
+	}{
+		{"oauth app token", oauthToken, "gho_"},
+		{"user-to-server token", userToken, "ghu_"},
+		{"refresh token", refreshToken, "ghr_"},
+		{"classic personal access token", classic, "ghp_"},
+		{"fine-grained personal access token", fineGrainedToken, "github_pat_"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.HasPrefix(tt.token, tt.prefix) || !mockghauth.ValidToken(tt.token) {
+				t.Errorf("token = %q, expected a valid %s token", tt.token, tt.prefix)
+			}
+		})
+	}
+
+	if w = apiRequest(t, svr, "/api/v3/user", classic); w.Code != http.StatusOK {
+		t.Errorf("/api/v3/user with personal access token status = %d, expected = %d", w.Code, http.StatusOK)
+	}
+
+	if _, err = svr.IssuePersonalAccessToken("octocat", mockghauth.TokenKindOAuth, nil); !errors.Is(
+		err, mockghauth.ErrUnsupportedTokenKind,
+	) {
+		t.Errorf("IssuePersonalAccessToken() error: expected = %v, received = %v",
+			mockghauth.ErrUnsupportedTokenKind, err)
+	}
+}
+
+func applicationsRequest(
+	t *testing.T, svr *mockghauth.Server, method, path, clientSecret, token string,
+) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(`{"access_token":"`+token+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	if clientSecret != "" {
+		req.SetBasicAuth("test-client", clientSecret)
+	}
+
+	return doRequest(t, svr, req)
+}
+
+func TestServer_ApplicationsToken(t *testing.T) {
+	svr := newTestServer(t, nil)
+
+	token, _ := issueToken(t, svr, url.Values{"scope": {"read:org"}})["access_token"].(string)
+
+	const checkPath = "/api/v3/applications/test-client/token"
+
+	tests := []struct {
+		name       string
+		path       string
+		secret     string
+		token      string
+		wantStatus int
+	}{
+		{"no client credentials", checkPath, "", token, http.StatusUnauthorized},
+		{"wrong client secret", checkPath, "wrong", token, http.StatusNotFound},
+		{"other client in path", "/api/v3/applications/other-client/token", "secret", token, http.StatusNotFound},
+		{"missing access token", checkPath, "secret", "", http.StatusUnprocessableEntity},
+		{"unknown access token", checkPath, "secret", "gho_unknown", http.StatusNotFound},
+		{"valid access token", checkPath, "secret", token, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := applicationsRequest(t, svr, http.MethodPost, tt.path, tt.secret, tt.token)
+			if w.Code != tt.wantStatus {
+				t.Errorf("POST %s status = %d, expected = %d", tt.path, w.Code, tt.wantStatus)
+			}
+		})
+	}
+
+	w := applicationsRequest(t, svr, http.MethodPost, checkPath, "secret", token)
+
+	auth := mockghauth.GitHubAuthorization{}
+	if err := json.NewDecoder(w.Body).Decode(&auth); err != nil {
+		t.Fatalf("check token decode error = %v", err)
+	}
+
+	if auth.Token != token || auth.TokenLastEight != token[len(token)-8:] {
+		t.Errorf("check token = %q (%q), expected = %q", auth.Token, auth.TokenLastEight, token)
+	}
+
+	if auth.App.ClientID != "test-client" || auth.User == nil || auth.User.Login != "octocat" {
+		t.Errorf("check token app = %+v, user = %+v, expected test-client and octocat", auth.App, auth.User)
+	}
+
+	if len(auth.Scopes) != 1 || auth.Scopes[0] != "read:org" || auth.HashedToken == "" || auth.ID == 0 {
+		t.Errorf("check token scopes = %v, hashed_token = %q, id = %d", auth.Scopes, auth.HashedToken, auth.ID)
+	}
+
+	w = applicationsRequest(t, svr, http.MethodPatch, checkPath, "secret", token)
+
+	reset := mockghauth.GitHubAuthorization{}
+	if err := json.NewDecoder(w.Body).Decode(&reset); err != nil {
+		t.Fatalf("reset token decode error = %v", err)
+	}
+
+	if reset.Token == token || !strings.HasPrefix(reset.Token, "gho_") || reset.ID != auth.ID {
+		t.Errorf("reset token = %q (id %d), expected a new token for authorization %d", reset.Token, reset.ID, auth.ID)
+	}
+
+	if w = apiRequest(t, svr, "/api/v3/user", token); w.Code != http.StatusUnauthorized {
+		t.Errorf("old token after reset status = %d, expected = %d", w.Code, http.StatusUnauthorized)
+	}
+
+	if w = apiRequest(t, svr, "/api/v3/user", reset.Token); w.Code != http.StatusOK {
+		t.Errorf("new token after reset status = %d, expected = %d", w.Code, http.StatusOK)
+	}
+
+	w = applicationsRequest(t, svr, http.MethodDelete, checkPath, "secret", reset.Token)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("delete token status = %d, expected = %d", w.Code, http.StatusNoContent)
+	}
+
+	if w = apiRequest(t, svr, "/api/v3/user", reset.Token); w.Code != http.StatusUnauthorized {
+		t.Errorf("token after delete status = %d, expected = %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestServer_ApplicationsGrant(t *testing.T) {
+	svr := newTestServer(t, nil)
+
+	first, _ := issueToken(t, svr, url.Values{})["access_token"].(string)
+	second, _ := issueToken(t, svr, url.Values{})["access_token"].(string)
+
+	w := applicationsRequest(t, svr, http.MethodDelete, "/api/v3/applications/test-client/grant", "secret", first)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("delete grant status = %d, expected = %d", w.Code, http.StatusNoContent)
+	}
+
+	for _, token := range []string{first, second} {
+		if w = apiRequest(t, svr, "/api/v3/user", token); w.Code != http.StatusUnauthorized {
+			t.Errorf("token after grant delete status = %d, expected = %d", w.Code, http.StatusUnauthorized)
+		}
+	}
+}
+
+func appRequest(t *testing.T, svr *mockghauth.Server, method, path, jwt, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if jwt != "" {
+		req.Header.Set("Authorization", "Bearer "+jwt)
+	}
+
+	return doRequest(t, svr, req)
+}
+
+func TestServer_GitHubApp(t *testing.T) {
+	svr := newTestServer(t, map[string]any{
+		"load.apps-file":  "../testdata/apps.json",
+		"load.users-file": "../testdata/users.json",
+	})
+
+	key := loadAppKey(t)
+
+	jwt, err := mockghauth.NewAppJWT(key, "1", time.Now().Add(-time.Minute), time.Now().Add(9*time.Minute))
+	if err != nil {
+		t.Fatalf("NewAppJWT() error = %v", err)
+	}
+
+	expired, err := mockghauth.NewAppJWT(key, "1", time.Now().Add(-time.Hour), time.Now().Add(-50*time.Minute))
+	if err != nil {
+		t.Fatalf("NewAppJWT() error = %v", err)
+	}
+
+	authTests := []struct {
+		name        string
+		jwt         string
+		wantStatus  int
+		wantMessage string
+	}{
+		{"no jwt", "", http.StatusUnauthorized, "Must authenticate to access this API."},
+		{"garbage jwt", "garbage", http.StatusUnauthorized, "A JSON web token could not be decoded"},
+		{"expired jwt", expired, http.StatusUnauthorized, "'Expiration time' claim ('exp') must be a numeric value " +
+			"representing the future time at which the assertion expires"},
+		{"valid jwt", jwt, http.StatusOK, ""},
+	}
+	for _, tt := range authTests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := appRequest(t, svr, http.MethodGet, "/api/v3/app", tt.jwt, "")
+			if w.Code != tt.wantStatus {
+				t.Errorf("/api/v3/app status = %d, expected = %d", w.Code, tt.wantStatus)
+			}
+
+			body := map[string]any{}
+			if decodeErr := json.NewDecoder(w.Body).Decode(&body); decodeErr != nil {
+				t.Fatalf("/api/v3/app decode error = %v", decodeErr)
+			}
+
+			if v, _ := body["message"].(string); tt.wantMessage != "" && v != tt.wantMessage {
+				t.Errorf("/api/v3/app message = %q, expected = %q", v, tt.wantMessage)
+			}
+
+			if v, _ := body["slug"].(string); tt.wantStatus == http.StatusOK && v != "mock-app" {
+				t.Errorf("/api/v3/app slug = %q, expected = %q", v, "mock-app")
+			}
+		})
+	}
+
+	w := appRequest(t, svr, http.MethodGet, "/api/v3/app/installations", jwt, "")
+
+	installations := []mockghauth.GitHubInstallation{}
+	if err = json.NewDecoder(w.Body).Decode(&installations); err != nil {
+		t.Fatalf("/api/v3/app/installations decode error = %v", err)
+	}
+
+	if len(installations) != 3 || installations[0].Account == nil || installations[0].Account.Login != "octocat" {
+		t.Errorf("/api/v3/app/installations = %+v, expected octocat, hubot and github installations", installations)
+	}
+
+	tokenTests := []struct {
+		name          string
+		installation  string
+		body          string
+		wantStatus    int
+		wantSelection string
+	}{
+		{"unknown installation", "999", "", http.StatusNotFound, ""},
+		{"all repositories", "100", "", http.StatusCreated, "all"},
+		{"narrowed repositories", "100", `{"repositories":["hello-world"]}`, http.StatusCreated, "selected"},
+		{"narrowed permissions", "100", `{"permissions":{"issues":"read"}}`, http.StatusCreated, "all"},
+		{"permission not granted", "200", `{"permissions":{"issues":"write"}}`, http.StatusUnprocessableEntity, ""},
+		{"repository not selected", "200", `{"repositories":["other"]}`, http.StatusUnprocessableEntity, ""},
+		{"selected installation", "200", "", http.StatusCreated, "selected"},
+	}
+	for _, tt := range tokenTests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "/api/v3/app/installations/" + tt.installation + "/access_tokens"
+
+			w := appRequest(t, svr, http.MethodPost, path, jwt, tt.body)
+			if w.Code != tt.wantStatus {
+				t.Errorf("%s status = %d, expected = %d", path, w.Code, tt.wantStatus)
+			}
+
+			if tt.wantStatus != http.StatusCreated {
+				return
+			}
+
+			got := mockghauth.GitHubInstallationToken{}
+			if decodeErr := json.NewDecoder(w.Body).Decode(&got); decodeErr != nil {
+				t.Fatalf("%s decode error = %v", path, decodeErr)
+			}
+
+			if !strings.HasPrefix(got.Token, "ghs_") || !mockghauth.ValidToken(got.Token) {
+				t.Errorf("%s token = %q, expected a valid ghs_ token", path, got.Token)
+			}
+
+			if got.RepositorySelection != tt.wantSelection {
+				t.Errorf("%s repository_selection = %q, expected = %q", path, got.RepositorySelection, tt.wantSelection)
+			}
+
+			if until := time.Until(got.ExpiresAt); until < 59*time.Minute || until > time.Hour {
+				t.Errorf("%s expires_at = %s, expected one hour from now", path, got.ExpiresAt)
+			}
+
+			if w = apiRequest(t, svr, "/api/v3/user", got.Token); w.Code != http.StatusForbidden {
+				t.Errorf("/api/v3/user with installation token status = %d, expected = %d",
+					w.Code, http.StatusForbidden)
+			}
+
+			w = apiRequest(t, svr, "/api/v3/user/orgs", got.Token)
+
+			body := map[string]any{}
+			if decodeErr := json.NewDecoder(w.Body).Decode(&body); decodeErr != nil {
+				t.Fatalf("/api/v3/user/orgs decode error = %v", decodeErr)
+			}
+
+			if v, _ := body["message"].(string); w.Code != http.StatusForbidden ||
+				v != "Resource not accessible by integration" {
+				t.Errorf("/api/v3/user/orgs with installation token status = %d, message = %q", w.Code, v)
+			}
+		})
+	}
+}
+
+func TestServer_InstallationRepositories(t *testing.T) {
+	svr := newTestServer(t, map[string]any{
+		"load.apps-file":  "../testdata/apps.json",
+		"load.users-file": "../testdata/users.json",
+		"load.repos-file": "../testdata/repos.json",
+	})
+
+	jwt, err := mockghauth.NewAppJWT(loadAppKey(t), "1", time.Now().Add(-time.Minute), time.Now().Add(9*time.Minute))
+	if err != nil {
+		t.Fatalf("NewAppJWT() error = %v", err)
+	}
+
+	oauthToken, _ := issueToken(t, svr, url.Values{"scope": {"repo"}})["access_token"].(string)
+	if w := apiRequest(t, svr, "/api/v3/installation/repositories", oauthToken); w.Code != http.StatusForbidden {
+		t.Errorf("/api/v3/installation/repositories with oauth token status = %d, expected = %d",
+			w.Code, http.StatusForbidden)
+	}
+
+	tests := []struct {
+		name             string
+		installation     string
+		body             string
+		wantSelection    string
+		wantRepos        []string
+		wantPrivate      int
+		wantCollaborator int
+	}{
+		{
+			name:             "all repositories",
+			installation:     "100",
+			wantSelection:    "all",
+			wantRepos:        []string{"octocat/hello-world", "octocat/secret-plans"},
+			wantPrivate:      http.StatusOK,
+			wantCollaborator: http.StatusOK,
+		},
+		{
+			name:             "narrowed repositories",
+			installation:     "100",
+			body:             `{"repositories":["hello-world"]}`,
+			wantSelection:    "selected",
+			wantRepos:        []string{"octocat/hello-world"},
+			wantPrivate:      http.StatusNotFound,
+			wantCollaborator: http.StatusOK,
+		},
+		{
+			name:             "without metadata or contents permission",
+			installation:     "100",
+			body:             `{"permissions":{"issues":"read"}}`,
+			wantSelection:    "all",
+			wantRepos:        []string{},
+			wantPrivate:      http.StatusNotFound,
+			wantCollaborator: http.StatusForbidden,
+		},
+		{
+			name:             "selected without repositories",
+			installation:     "300",
+			wantSelection:    "selected",
+			wantRepos:        []string{},
+			wantPrivate:      http.StatusNotFound,
+			wantCollaborator: http.StatusForbidden,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "/api/v3/app/installations/" + tt.installation + "/access_tokens"
+			w := appRequest(t, svr, http.MethodPost, path, jwt, tt.body)
+
+			minted := mockghauth.GitHubInstallationToken{}
+			if decodeErr := json.NewDecoder(w.Body).Decode(&minted); decodeErr != nil {
+				t.Fatalf("access_tokens decode error = %v", decodeErr)
+			}
+
+			w = apiRequest(t, svr, "/api/v3/installation/repositories", minted.Token)
+			if w.Code != http.StatusOK {
+				t.Fatalf("/api/v3/installation/repositories status = %d, expected = %d", w.Code, http.StatusOK)
+			}
+
+			got := mockghauth.GitHubInstallationRepositories{}
+			if decodeErr := json.NewDecoder(w.Body).Decode(&got); decodeErr != nil {
+				t.Fatalf("/api/v3/installation/repositories decode error = %v", decodeErr)
+			}
+
+			repos := []string{}
+			for _, v := range got.Repositories {
+				repos = append(repos, v.FullName)
+			}
+
+			if !slices.Equal(repos, tt.wantRepos) || got.TotalCount != len(tt.wantRepos) {
+				t.Errorf("/api/v3/installation/repositories = %v (total_count %d), expected = %v",
+					repos, got.TotalCount, tt.wantRepos)
+			}
+
+			if got.RepositorySelection != tt.wantSelection {
+				t.Errorf("/api/v3/installation/repositories repository_selection = %q, expected = %q",
+					got.RepositorySelection, tt.wantSelection)
+			}
+
+			if w = apiRequest(t, svr, "/api/v3/repos/octocat/secret-plans", minted.Token); w.Code != tt.wantPrivate {
+				t.Errorf("/api/v3/repos/octocat/secret-plans status = %d, expected = %d", w.Code, tt.wantPrivate)
+			}
+
+			path = "/api/v3/repos/octocat/hello-world/collaborators/hubot/permission"
+			if w = apiRequest(t, svr, path, minted.Token); w.Code != tt.wantCollaborator {
+				t.Errorf("%s status = %d, expected = %d", path, w.Code, tt.wantCollaborator)
+			}
+		})
+	}
+}
+
+func TestServer_OIDC(t *testing.T) {
+	svr := newTestServer(t, map[string]any{
+		"load.oidc-key-file": "../testdata/app-private-key.pem",
+		"oidc.audience":      "https://example.com",
+	})
+
+	w := doRequest(t, svr, httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil))
+
+	discovery := mockghauth.OIDCConfiguration{}
+	if err := json.NewDecoder(w.Body).Decode(&discovery); err != nil {
+		t.Fatalf("openid-configuration decode error = %v", err)
+	}
+
+	if discovery.Issuer != "http://localhost:8080" || discovery.JWKSURI != "http://localhost:8080/.well-known/jwks" {
+		t.Errorf("openid-configuration issuer = %q, jwks_uri = %q", discovery.Issuer, discovery.JWKSURI)
+	}
+
+	w = doRequest(t, svr, httptest.NewRequest(http.MethodGet, "/.well-known/jwks", nil))
+
+	jwks := mockghauth.JWKS{}
+	if err := json.NewDecoder(w.Body).Decode(&jwks); err != nil {
+		t.Fatalf("jwks decode error = %v", err)
+	}
+
+	if len(jwks.Keys) != 1 {
+		t.Fatalf("jwks keys = %d, expected = 1", len(jwks.Keys))
+	}
+
+	n, _ := base64.RawURLEncoding.DecodeString(jwks.Keys[0].N)
+	e, _ := base64.RawURLEncoding.DecodeString(jwks.Keys[0].E)
+	key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+
+	req := httptest.NewRequest(http.MethodPost, "/_mock/oidc/token?audience=sts.amazonaws.com&ref=refs/heads/dev",
+		strings.NewReader(`{"repository":"acme/app","environment":"prod"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = doRequest(t, svr, req)
+
+	resp := map[string]string{}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("/_mock/oidc/token decode error = %v", err)
+	}
+
+	header, claims := verifyIDToken(t, key, resp["value"])
+	if header["kid"] != jwks.Keys[0].Kid {
+		t.Errorf("ID token kid = %v, expected = %q", header["kid"], jwks.Keys[0].Kid)
+	}
+
+	wantClaims := map[string]any{
+		"iss":         "http://localhost:8080",
+		"aud":         "sts.amazonaws.com",
+		"sub":         "repo:acme/app:ref:refs/heads/dev",
+		"repository":  "acme/app",
+		"environment": "prod",
+		"actor":       "octocat",
+	}
+	for k, want := range wantClaims {
+		if claims[k] != want {
+			t.Errorf("ID token claim %s: expected = %v, received = %v", k, want, claims[k])
+		}
+	}
+
+	audienceTests := []struct {
+		name    string
+		method  string
+		query   string
+		body    string
+		wantAud string
+	}{
+		{"default audience", http.MethodPost, "", "", "https://example.com"},
+		{"runtime GET with query audience", http.MethodGet, "?audience=api://query", "", "api://query"},
+		{"body audience", http.MethodPost, "", `{"audience":"api://body"}`, "api://body"},
+	}
+	for _, tt := range audienceTests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/_mock/oidc/token"+tt.query, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := doRequest(t, svr, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("%s /_mock/oidc/token status = %d, expected = %d", tt.method, w.Code, http.StatusOK)
+			}
+
+			got := map[string]string{}
+			if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+				t.Fatalf("/_mock/oidc/token decode error = %v", err)
+			}
+
+			if _, idClaims := verifyIDToken(t, key, got["value"]); idClaims["aud"] != tt.wantAud {
+				t.Errorf("ID token aud = %v, expected = %q", idClaims["aud"], tt.wantAud)
+			}
+		})
+	}
+}
+
+func TestServer_OIDCIDClaims(t *testing.T) {
+	svr := newTestServer(t, map[string]any{
+		"load.oidc-key-file": "../testdata/app-private-key.pem",
+		"load.users-file":    "../testdata/users.json",
+		"load.orgs-file":     "../testdata/orgs.json",
+		"load.repos-file":    "../testdata/repos.json",
+	})
+
+	key := loadAppKey(t)
+
+	tests := []struct {
+		name string
+		body string
+		want map[string]any
+	}{
+		{"user repository", `{"repository":"hubot/scripts","actor":"outsider"}`, map[string]any{
+			"repository_id": "1296271", "repository_owner_id": "2", "actor_id": "3",
+		}},
+		{"organization repository", `{"repository":"github/platform","actor":"hubot"}`, map[string]any{
+			"repository_id": "1296272", "repository_owner_id": "9919", "actor_id": "2",
+		}},
+		{"explicit ids", `{"repository":"hubot/scripts","repository_id":"42","actor_id":"43"}`, map[string]any{
+			"repository_id": "42", "repository_owner_id": "2", "actor_id": "43",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/_mock/oidc/token", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := doRequest(t, svr, req)
+
+			resp := map[string]string{}
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatalf("/_mock/oidc/token decode error = %v", err)
+			}
+
+			_, claims := verifyIDToken(t, &key.PublicKey, resp["value"])
+			for k, want := range tt.want {
+				if claims[k] != want {
+					t.Errorf("ID token claim %s: expected = %v, received = %v", k, want, claims[k])
+				}
+			}
+		})
+	}
+}
+
+func TestServer_UserEmails(t *testing.T) {
+	svr := newTestServer(t, map[string]any{"load.users-file": "../testdata/users.json"})
+
+	tests := []struct {
+		name       string
+		login      string
+		scopes     mockghauth.Scopes
+		path       string
+		wantStatus int
+		wantEmails []string
+	}{
+		{"multiple emails", "octocat", mockghauth.Scopes{"user:email"}, "/api/v3/user/emails", http.StatusOK, []string{
+			"octocat@github.com", "mona@example.com", "1+octocat@users.noreply.github.com", "octocat@example.org",
+		}},
+		{"public emails only", "octocat", mockghauth.Scopes{"user"}, "/api/v3/user/public_emails", http.StatusOK,
+			[]string{"octocat@github.com"}},
+		{"no verified email", "hubot", mockghauth.Scopes{"user:email"}, "/api/v3/user/emails", http.StatusOK,
+			[]string{"hubot@github.com"}},
+		{"private email is not public", "hubot", mockghauth.Scopes{"user:email"}, "/api/v3/user/public_emails",
+			http.StatusOK, []string{}},
+		{"no emails", "outsider", mockghauth.Scopes{"user:email"}, "/api/v3/user/emails", http.StatusOK, []string{}},
+		{"without user:email", "octocat", mockghauth.Scopes{"read:user"}, "/api/v3/user/emails", http.StatusNotFound,
+			nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := svr.IssuePersonalAccessToken(tt.login, mockghauth.TokenKindPersonal, tt.scopes)
+			if err != nil {
+				t.Fatalf("Server.IssuePersonalAccessToken() error = %v", err)
+			}
+
+			w := apiRequest(t, svr, tt.path, token)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("%s status = %d, expected = %d", tt.path, w.Code, tt.wantStatus)
+			}
+
+			if tt.wantEmails == nil {
+				return
+			}
+
+			emails := []mockghauth.GitHubAPIEmail{}
+			if err = json.NewDecoder(w.Body).Decode(&emails); err != nil {
+				t.Fatalf("%s decode error = %v", tt.path, err)
+			}
+
+			got := make([]string, 0, len(emails))
+			for _, v := range emails {
+				got = append(got, v.Email)
+			}
+
+			if !slices.Equal(got, tt.wantEmails) {
+				t.Errorf("%s emails = %v, expected = %v", tt.path, got, tt.wantEmails)
+			}
+		})
+	}
+}
+
+func TestServer_UserPublicEmail(t *testing.T) {
+	svr := newTestServer(t, map[string]any{"load.users-file": "../testdata/users.json"})
+
+	tests := []struct {
+		name           string
+		login          string
+		scopes         mockghauth.Scopes
+		wantUserEmail  any
+		wantUsersEmail any
+	}{
+		{"public email without scope", "octocat", nil, "octocat@github.com", "octocat@github.com"},
+		{"private email without scope", "hubot", nil, nil, nil},
+		{"private email with user:email", "hubot", mockghauth.Scopes{"user:email"}, "hubot@github.com", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := svr.IssuePersonalAccessToken(tt.login, mockghauth.TokenKindPersonal, tt.scopes)
+			if err != nil {
+				t.Fatalf("Server.IssuePersonalAccessToken() error = %v", err)
+			}
+
+			for path, want := range map[string]any{
+				"/api/v3/user":              tt.wantUserEmail,
+				"/api/v3/users/" + tt.login: tt.wantUsersEmail,
+			} {
+				body := map[string]any{}
+				if err = json.NewDecoder(apiRequest(t, svr, path, token).Body).Decode(&body); err != nil {
+					t.Fatalf("%s decode error = %v", path, err)
+				}
+
+				if body["email"] != want {
+					t.Errorf("%s email = %v, expected = %v", path, body["email"], want)
+				}
+			}
+		})
+	}
+}
+
+func TestServer_OrgMembership(t *testing.T) {
+	svr := newTestServer(t, map[string]any{
+		"load.users-file": "../testdata/users.json",
+		"load.orgs-file":  "../testdata/orgs.json",
+	})
+
+	tests := []struct {
+		name         string
+		path         string
+		token        string
+		wantStatus   int
+		wantLocation string
+		wantFields   map[string]string
+	}{
+		{"member check", "/api/v3/orgs/github/members/hubot",
+			personalToken(t, svr, "octocat", "read:org"), http.StatusNoContent, "", nil},
+		{"member check pending", "/api/v3/orgs/github/members/outsider",
+			personalToken(t, svr, "octocat", "read:org"), http.StatusNotFound, "", nil},
+		{"member check without read:org", "/api/v3/orgs/github/members/hubot",
+			personalToken(t, svr, "octocat"), http.StatusFound,
+			"http://localhost:8080/api/v3/orgs/github/public_members/hubot", nil},
+		{"member check by non-member", "/api/v3/orgs/octo-org/members/octocat",
+			personalToken(t, svr, "hubot", "read:org"), http.StatusFound,
+			"http://localhost:8080/api/v3/orgs/octo-org/public_members/octocat", nil},
+		{"member check anonymous", "/api/v3/orgs/github/members/octocat", "",
+			http.StatusFound, "http://localhost:8080/api/v3/orgs/github/public_members/octocat", nil},
+		{"member check unknown org", "/api/v3/orgs/nope/members/octocat",
+			personalToken(t, svr, "octocat", "read:org"), http.StatusNotFound, "", nil},
+		{"public member", "/api/v3/orgs/github/public_members/octocat", "", http.StatusNoContent, "", nil},
+		{"concealed member", "/api/v3/orgs/github/public_members/hubot", "", http.StatusNotFound, "", nil},
+		{"membership", "/api/v3/orgs/github/memberships/octocat",
+			personalToken(t, svr, "hubot", "read:org"), http.StatusOK, "",
+			map[string]string{"state": "active", "role": "admin"}},
+		{"own pending membership", "/api/v3/orgs/github/memberships/outsider",
+			personalToken(t, svr, "outsider", "read:org"), http.StatusOK, "",
+			map[string]string{"state": "pending", "role": "member"}},
+		{"membership by non-member", "/api/v3/orgs/github/memberships/octocat",
+			personalToken(t, svr, "outsider", "read:org"), http.StatusForbidden, "", nil},
+		{"membership without read:org", "/api/v3/orgs/github/memberships/octocat",
+			personalToken(t, svr, "hubot", "user"), http.StatusForbidden, "", nil},
+		{"membership of non-member", "/api/v3/orgs/octo-org/memberships/hubot",
+			personalToken(t, svr, "octocat", "read:org"), http.StatusNotFound, "", nil},
+		{"team membership", "/api/v3/orgs/github/teams/justice-league/memberships/octocat",
+			personalToken(t, svr, "hubot", "read:org"), http.StatusOK, "",
+			map[string]string{"state": "active", "role": "maintainer"}},
+		{"pending team membership", "/api/v3/orgs/github/teams/justice-league/memberships/hubot",
+			personalToken(t, svr, "octocat", "read:org"), http.StatusOK, "",
+			map[string]string{"state": "pending", "role": "member"}},
+		{"team membership of non-member", "/api/v3/orgs/github/teams/robots/memberships/octocat",
+			personalToken(t, svr, "octocat", "read:org"), http.StatusNotFound, "", nil},
+		{"team membership by non-member", "/api/v3/orgs/github/teams/robots/memberships/hubot",
+			personalToken(t, svr, "outsider", "read:org"), http.StatusNotFound, "", nil},
+		{"unknown team", "/api/v3/orgs/github/teams/nope/memberships/hubot",
+			personalToken(t, svr, "octocat", "read:org"), http.StatusNotFound, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := apiRequest(t, svr, tt.path, tt.token)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("%s status = %d, expected = %d", tt.path, w.Code, tt.wantStatus)
+			}
+
+			if v := w.Header().Get("Location"); v != tt.wantLocation {
+				t.Errorf("%s Location = %q, expected = %q", tt.path, v, tt.wantLocation)
+			}
+
+			if tt.wantFields == nil {
+				return
+			}
+
+			body := map[string]any{}
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatalf("%s decode error = %v", tt.path, err)
+			}
+
+			for k, want := range tt.wantFields {
+				if body[k] != want {
+					t.Errorf("%s %s: expected = %q, received = %v", tt.path, k, want, body[k])
+				}
+			}
+		})
+	}
+}
+
+func TestServer_UserOrgsAndTeams(t *testing.T) {
+	svr := newTestServer(t, map[string]any{
+		"load.users-file": "../testdata/users.json",
+		"load.orgs-file":  "../testdata/orgs.json",
+	})
+
+	tests := []struct {
+		login     string
+		wantOrgs  []string
+		wantTeams []string
+	}{
+		{"octocat", []string{"github", "octo-org"}, []string{"justice-league"}},
+		{"hubot", []string{"github"}, []string{"robots"}},
+		{"outsider", []string{}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.login, func(t *testing.T) {
+			token := personalToken(t, svr, tt.login, "read:org")
+
+			orgs := []mockghauth.GitHubAPIOrganization{}
+			if err := json.NewDecoder(apiRequest(t, svr, "/api/v3/user/orgs", token).Body).Decode(&orgs); err != nil {
+				t.Fatalf("/api/v3/user/orgs decode error = %v", err)
+			}
+
+			gotOrgs := []string{}
+			for _, v := range orgs {
+				gotOrgs = append(gotOrgs, v.Login)
+			}
+
+			if !slices.Equal(gotOrgs, tt.wantOrgs) {
+				t.Errorf("/api/v3/user/orgs = %v, expected = %v", gotOrgs, tt.wantOrgs)
+			}
+
+			teams := []mockghauth.GitHubAPITeam{}
+			if err := json.NewDecoder(apiRequest(t, svr, "/api/v3/user/teams", token).Body).Decode(&teams); err != nil {
+				t.Fatalf("/api/v3/user/teams decode error = %v", err)
+			}
+
+			gotTeams := []string{}
+			for _, v := range teams {
+				gotTeams = append(gotTeams, v.Slug)
+
+				if v.Organization == nil || v.Organization.Login != "github" {
+					t.Errorf("/api/v3/user/teams %s organization = %+v", v.Slug, v.Organization)
+				}
+			}
+
+			if !slices.Equal(gotTeams, tt.wantTeams) {
+				t.Errorf("/api/v3/user/teams = %v, expected = %v", gotTeams, tt.wantTeams)
+			}
+		})
+	}
+}
+
+func TestServer_APIMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]any
+		path     string
+		wantUser string
+		wantOrgs string
+		missing  string
+	}{
+		{
+			name:     "ghes",
+			path:     "/api/v3/user",
+			wantUser: "http://localhost:8080/api/v3/users/octocat",
+			wantOrgs: "http://localhost:8080/api/v3/users/octocat/orgs",
+			missing:  "/user",
+		},
+		{
+			name:     "dotcom",
+			settings: map[string]any{"api.mode": "dotcom"},
+			path:     "/user",
+			wantUser: "http://localhost:8080/users/octocat",
+			wantOrgs: "http://localhost:8080/users/octocat/orgs",
+			missing:  "/api/v3/user",
+		},
+		{
+			name:     "host",
+			settings: map[string]any{"api.mode": "host", "api.bind": "api.localhost:8081"},
+			path:     "/user",
+			wantUser: "http://api.localhost:8081/users/octocat",
+			wantOrgs: "http://api.localhost:8081/users/octocat/orgs",
+			missing:  "/user",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svr := newTestServer(t, tt.settings)
+
+			resp := issueToken(t, svr, url.Values{})
+			token, _ := resp["access_token"].(string)
+
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("Authorization", "token "+token)
+
+			w := httptest.NewRecorder()
+			svr.APIHandler().ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("%s status = %d, expected = %d", tt.path, w.Code, http.StatusOK)
+			}
+
+			user := mockghauth.GitHubAPIUser{}
+			if err := json.NewDecoder(w.Body).Decode(&user); err != nil {
+				t.Fatalf("%s decode error = %v", tt.path, err)
+			}
+
+			if user.URL != tt.wantUser || user.OrganizationsURL != tt.wantOrgs {
+				t.Errorf("%s url = %q, organizations_url = %q, expected = %q, %q",
+					tt.path, user.URL, user.OrganizationsURL, tt.wantUser, tt.wantOrgs)
+			}
+
+			if user.HTMLURL != "http://localhost:8080/octocat" {
+				t.Errorf("%s html_url = %q, expected on the main host", tt.path, user.HTMLURL)
+			}
+
+			if w = apiRequest(t, svr, tt.missing, token); w.Code != http.StatusNotFound {
+				t.Errorf("%s status = %d, expected = %d", tt.missing, w.Code, http.StatusNotFound)
+			}
+		})
+	}
+}
+
+func TestServer_UsersProfile(t *testing.T) {
+	svr := newTestServer(t, map[string]any{
+		"load.users-file": "../testdata/users.json",
+		"load.orgs-file":  "../testdata/orgs.json",
+	})
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantLogins []string
+	}{
+		{"followers", "/api/v3/users/octocat/followers", http.StatusOK, []string{"hubot", "outsider"}},
+		{"following", "/api/v3/users/outsider/following", http.StatusOK, []string{"octocat"}},
+		{"orgs are public memberships", "/api/v3/users/octocat/orgs", http.StatusOK, []string{"github"}},
+		{"concealed orgs", "/api/v3/users/hubot/orgs", http.StatusOK, []string{}},
+		{"repos", "/api/v3/users/octocat/repos", http.StatusOK, []string{}},
+		{"unknown user", "/api/v3/users/ghost", http.StatusNotFound, nil},
+		{"unknown user followers", "/api/v3/users/ghost/followers", http.StatusNotFound, nil},
+		{"follows", "/api/v3/users/octocat/following/hubot", http.StatusNoContent, nil},
+		{"does not follow", "/api/v3/users/hubot/following/outsider", http.StatusNotFound, nil},
+		{"follows unknown user", "/api/v3/users/outsider/following/ghost", http.StatusNotFound, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := apiRequest(t, svr, tt.path, "")
+			if w.Code != tt.wantStatus {
+				t.Fatalf("%s status = %d, expected = %d", tt.path, w.Code, tt.wantStatus)
+			}
+
+			if tt.wantLogins == nil {
+				return
+			}
+
+			items := []map[string]any{}
+			if err := json.NewDecoder(w.Body).Decode(&items); err != nil {
+				t.Fatalf("%s decode error = %v", tt.path, err)
+			}
+
+			got := []string{}
+			for _, v := range items {
+				login, _ := v["login"].(string)
+				got = append(got, login)
+			}
+
+			if !slices.Equal(got, tt.wantLogins) {
+				t.Errorf("%s logins = %v, expected = %v", tt.path, got, tt.wantLogins)
+			}
+		})
+	}
+
+	t.Run("profile", func(t *testing.T) {
+		w := apiRequest(t, svr, "/api/v3/users/octocat", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("/api/v3/users/octocat status = %d, expected = %d", w.Code, http.StatusOK)
+		}
+
+		body := map[string]any{}
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("/api/v3/users/octocat decode error = %v", err)
+		}
+
+		wantFields := map[string]any{
+			"login":     "octocat",
+			"email":     "octocat@github.com",
+			"followers": float64(2),
+			"following": float64(1),
+		}
+		for k, want := range wantFields {
+			if body[k] != want {
+				t.Errorf("/api/v3/users/octocat %s: expected = %v, received = %v", k, want, body[k])
+			}
+		}
+
+		if _, ok := body["plan"]; ok {
+			t.Errorf("/api/v3/users/octocat plan expected to be absent")
+		}
+
+		// Every advertised resource URL resolves.
+		for _, field := range []string{"url", "followers_url", "organizations_url", "repos_url"} {
+			link, _ := body[field].(string)
+			if w = apiRequest(t, svr, strings.TrimPrefix(link, "http://localhost:8080"), ""); w.Code != http.StatusOK {
+				t.Errorf("%s %s status = %d, expected = %d", field, link, w.Code, http.StatusOK)
+			}
+		}
+	})
+}
+
+func TestServer_Repos(t *testing.T) {
+	svr := newTestServer(t, map[string]any{
+		"load.users-file": "../testdata/users.json",
+		"load.orgs-file":  "../testdata/orgs.json",
+		"load.repos-file": "../testdata/repos.json",
+	})
+
+	t.Run("user repos", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			token string
+			query string
+			want  []string
+		}{
+			{"owner with repo", personalToken(t, svr, "octocat", "repo"), "",
+				[]string{"github/platform", "octocat/hello-world", "octocat/secret-plans"}},
+			{"owner without repo", personalToken(t, svr, "octocat", "read:user"), "", []string{"octocat/hello-world"}},
+			{"owner private only", personalToken(t, svr, "octocat", "repo"), "?visibility=private",
+				[]string{"github/platform", "octocat/secret-plans"}},
+			{"collaborator and org member", personalToken(t, svr, "hubot", "repo"), "",
+				[]string{"github/platform", "hubot/scripts", "octocat/hello-world"}},
+			{"collaborator with repo", personalToken(t, svr, "outsider", "repo"), "",
+				[]string{"github/platform", "octocat/secret-plans"}},
+			{"collaborator without repo", personalToken(t, svr, "outsider"), "", []string{}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w := apiRequest(t, svr, "/api/v3/user/repos"+tt.query, tt.token)
+				if w.Code != http.StatusOK {
+					t.Fatalf("/api/v3/user/repos status = %d, expected = %d", w.Code, http.StatusOK)
+				}
+
+				repos := []mockghauth.GitHubAPIRepository{}
+				if err := json.NewDecoder(w.Body).Decode(&repos); err != nil {
+					t.Fatalf("/api/v3/user/repos decode error = %v", err)
+				}
+
+				got := []string{}
+				for _, v := range repos {
+					got = append(got, v.FullName)
+				}
+
+				if !slices.Equal(got, tt.want) {
+					t.Errorf("/api/v3/user/repos = %v, expected = %v", got, tt.want)
+				}
+			})
+		}
+	})
+
+	t.Run("repo", func(t *testing.T) {
+		tests := []struct {
+			name       string
+			path       string
+			token      string
+			wantStatus int
+			wantPerms  *mockghauth.GitHubAPIRepoPermissions
+		}{
+			{"public anonymous", "/api/v3/repos/octocat/hello-world", "", http.StatusOK, nil},
+			{"public collaborator", "/api/v3/repos/octocat/Hello-World", personalToken(t, svr, "hubot"),
+				http.StatusOK, &mockghauth.GitHubAPIRepoPermissions{Push: true, Triage: true, Pull: true}},
+			{"private anonymous", "/api/v3/repos/octocat/secret-plans", "", http.StatusNotFound, nil},
+			{"private without repo", "/api/v3/repos/octocat/secret-plans",
+				personalToken(t, svr, "outsider", "public_repo"), http.StatusNotFound, nil},
+			{"private without access", "/api/v3/repos/octocat/secret-plans", personalToken(t, svr, "hubot", "repo"),
+				http.StatusNotFound, nil},
+			{"private collaborator", "/api/v3/repos/octocat/secret-plans",
+				personalToken(t, svr, "outsider", "repo"), http.StatusOK,
+				&mockghauth.GitHubAPIRepoPermissions{Triage: true, Pull: true}},
+			{"org admin", "/api/v3/repos/github/platform", personalToken(t, svr, "octocat", "repo"), http.StatusOK,
+				&mockghauth.GitHubAPIRepoPermissions{
+					Admin: true, Maintain: true, Push: true, Triage: true, Pull: true,
+				}},
+			{"unknown", "/api/v3/repos/octocat/nope", personalToken(t, svr, "octocat", "repo"),
+				http.StatusNotFound, nil},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w := apiRequest(t, svr, tt.path, tt.token)
+				if w.Code != tt.wantStatus {
+					t.Fatalf("%s status = %d, expected = %d", tt.path, w.Code, tt.wantStatus)
+				}
+
+				if tt.wantStatus != http.StatusOK {
+					return
+				}
+
+				repo := mockghauth.GitHubAPIRepository{}
+				if err := json.NewDecoder(w.Body).Decode(&repo); err != nil {
+					t.Fatalf("%s decode error = %v", tt.path, err)
+				}
+
+				if tt.wantPerms == nil {
+					if repo.Permissions != nil {
+						t.Errorf("%s permissions = %+v, expected to be absent", tt.path, repo.Permissions)
+					}
+
+					return
+				}
+
+				if repo.Permissions == nil || *repo.Permissions != *tt.wantPerms {
+					t.Errorf("%s permissions = %+v, expected = %+v", tt.path, repo.Permissions, tt.wantPerms)
+				}
+			})
+		}
+	})
+
+	t.Run("collaborator permission", func(t *testing.T) {
+		tests := []struct {
+			name           string
+			path           string
+			token          string
+			wantStatus     int
+			wantPermission string
+			wantRole       string
+		}{
+			{"collaborator", "/api/v3/repos/octocat/hello-world/collaborators/hubot/permission",
+				personalToken(t, svr, "octocat"), http.StatusOK, "write", "write"},
+			{"public non-collaborator", "/api/v3/repos/octocat/hello-world/collaborators/outsider/permission",
+				personalToken(t, svr, "hubot"), http.StatusOK, "read", "read"},
+			{"triage is read", "/api/v3/repos/octocat/secret-plans/collaborators/outsider/permission",
+				personalToken(t, svr, "octocat", "repo"), http.StatusOK, "read", "triage"},
+			{"maintain is write", "/api/v3/repos/github/platform/collaborators/outsider/permission",
+				personalToken(t, svr, "outsider", "repo"), http.StatusOK, "write", "maintain"},
+			{"private non-collaborator", "/api/v3/repos/octocat/secret-plans/collaborators/hubot/permission",
+				personalToken(t, svr, "octocat", "repo"), http.StatusOK, "none", "none"},
+			{"without push access", "/api/v3/repos/octocat/hello-world/collaborators/hubot/permission",
+				personalToken(t, svr, "outsider"), http.StatusForbidden, "", ""},
+			{"unknown user", "/api/v3/repos/octocat/hello-world/collaborators/ghost/permission",
+				personalToken(t, svr, "octocat"), http.StatusNotFound, "", ""},
+			{"hidden repo", "/api/v3/repos/octocat/secret-plans/collaborators/outsider/permission",
+				personalToken(t, svr, "octocat"), http.StatusNotFound, "", ""},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w := apiRequest(t, svr, tt.path, tt.token)
+				if w.Code != tt.wantStatus {
+					t.Fatalf("%s status = %d, expected = %d", tt.path, w.Code, tt.wantStatus)
+				}
+
+				if tt.wantStatus != http.StatusOK {
+					return
+				}
+
+				perm := mockghauth.GitHubAPIRepoCollaboratorPermission{}
+				if err := json.NewDecoder(w.Body).Decode(&perm); err != nil {
+					t.Fatalf("%s decode error = %v", tt.path, err)
+				}
+
+				if perm.Permission != tt.wantPermission || perm.RoleName != tt.wantRole || perm.User == nil {
+					t.Errorf("%s permission = %q, role_name = %q, expected = %q, %q",
+						tt.path, perm.Permission, perm.RoleName, tt.wantPermission, tt.wantRole)
+				}
+			})
+		}
+	})
+
+	t.Run("users repos", func(t *testing.T) {
+		// Private repositories are not listed even to the owner with the repo
+		// scope.
+		for _, token := range []string{"", personalToken(t, svr, "octocat", "repo")} {
+			repos := []mockghauth.GitHubAPIRepository{}
+			w := apiRequest(t, svr, "/api/v3/users/octocat/repos", token)
+			if err := json.NewDecoder(w.Body).Decode(&repos); err != nil {
+				t.Fatalf("/api/v3/users/octocat/repos decode error = %v", err)
+			}
+
+			if len(repos) != 1 || repos[0].FullName != "octocat/hello-world" {
+				t.Errorf("/api/v3/users/octocat/repos received = %d repositories, expected octocat/hello-world only",
+					len(repos))
+			}
+		}
+	})
+}
@@ -0,0 +1,163 @@
+package mockghauth
+
+import (
+	"crypto/rand"
+	"hash/crc32"
+	"math/big"
+	"strings"
+)
+
+const (
+	base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+	tokenRandomLength   = 30
+	tokenChecksumLength = 6
+
+	fineGrainedPrefix     = "github_pat_"
+	fineGrainedIDLength   = 22
+	fineGrainedBodyLength = 59
+)
+
+// TokenKind is how a token was issued, which decides its format.
+type TokenKind string
+
+const (
+	// TokenKindOAuth is an OAuth app access token (gho_).
+	TokenKindOAuth TokenKind = "oauth"
+	// TokenKindUserToServer is an expiring GitHub App user access token (ghu_).
+	TokenKindUserToServer TokenKind = "user_to_server"
+	// TokenKindInstallation is a GitHub App installation access token (ghs_).
+	TokenKindInstallation TokenKind = "installation"
+	// TokenKindRefresh is a refresh token for a user-to-server token (ghr_).
+	TokenKindRefresh TokenKind = "refresh"
+	// TokenKindPersonal is a classic personal access token (ghp_).
+	TokenKindPersonal TokenKind = "personal"
+	// TokenKindFineGrained is a fine-grained personal access token
+	// (github_pat_).
+	TokenKindFineGrained TokenKind = "fine_grained"
+)
+
+// Prefix returns the prefix GitHub gives tokens of this kind, tokens with no
+// kind are OAuth app tokens.
+func (k TokenKind) Prefix() string {
+	switch k {
+	case TokenKindUserToServer:
+		return "ghu_"
+	case TokenKindInstallation:
+		return "ghs_"
+	case TokenKindRefresh:
+		return "ghr_"
+	case TokenKindPersonal:
+		return "ghp_"
+	case TokenKindFineGrained:
+		return fineGrainedPrefix
+	case TokenKindOAuth:
+		return "gho_"
+	}
+
+	return "gho_"
+}
+
+// Expires reports whether GitHub gives tokens of this kind an expiry. OAuth
+// app and personal access tokens last until they are revoked, as do tokens
+// without a kind, which are OAuth app tokens.
+func (k TokenKind) Expires() bool {
+	switch k {
+	case "", TokenKindOAuth, TokenKindPersonal, TokenKindFineGrained:
+		return false
+	}
+
+	return true
+}
+
+// GenerateToken returns a new random token in the format GitHub uses for kind.
+//
+// Fine-grained personal access tokens are github_pat_ followed by 22 and 59
+// base62 characters separated by an underscore. Every other kind is its
+// prefix, 30 random base62 characters and the 6 character base62 CRC32
+// checksum of those random characters.
+func GenerateToken(kind TokenKind) string {
+	if kind == TokenKindFineGrained {
+		return fineGrainedPrefix + randomBase62(fineGrainedIDLength) + "_" + randomBase62(fineGrainedBodyLength)
+	}
+
+	random := randomBase62(tokenRandomLength)
+
+	return kind.Prefix() + random + tokenChecksum(random)
+}
+
+// ValidToken reports whether token has the shape of a GitHub token and, for
+// the checksummed formats, a correct checksum.
+func ValidToken(token string) bool {
+	if body, ok := strings.CutPrefix(token, fineGrainedPrefix); ok {
+		id, secret, found := strings.Cut(body, "_")
+
+		return found && len(id) == fineGrainedIDLength && len(secret) == fineGrainedBodyLength &&
+			isBase62(id) && isBase62(secret)
+	}
+
+	prefix, body, found := strings.Cut(token, "_")
+	if !found || !knownTokenPrefix(prefix+"_") || len(body) != tokenRandomLength+tokenChecksumLength {
+		return false
+	}
+
+	random, checksum := body[:tokenRandomLength], body[tokenRandomLength:]
+
+	return isBase62(random) && tokenChecksum(random) == checksum
+}
+
+func knownTokenPrefix(prefix string) bool {
+	switch prefix {
+	case "gho_", "ghu_", "ghs_", "ghr_", "ghp_":
+		return true
+	}
+
+	return false
+}
+
+// tokenChecksum returns the CRC32 of random in base62, zero padded to six
+// characters.
+func tokenChecksum(random string) string {
+	sum := big.NewInt(int64(crc32.ChecksumIEEE([]byte(random))))
+	base := big.NewInt(int64(len(base62Alphabet)))
+	mod := new(big.Int)
+
+	out := make([]byte, tokenChecksumLength)
+	for i := tokenChecksumLength - 1; i >= 0; i-- {
+		sum.DivMod(sum, base, mod)
+		out[i] = base62Alphabet[mod.Int64()]
+	}
+
+	return string(out)
+}
+
+// randomBase62 returns length uniformly random base62 characters, bytes at
+// or above the largest multiple of 62 are discarded to avoid modulo bias.
+func randomBase62(length int) string {
+	limit := byte(256 / len(base62Alphabet) * len(base62Alphabet))
+
+	out := make([]byte, 0, length)
+	buf := make([]byte, length)
+
+	for len(out) < length {
+		_, _ = rand.Read(buf)
+
+		for _, b := range buf {
+			if b < limit && len(out) < length {
+				out = append(out, base62Alphabet[int(b)%len(base62Alphabet)])
+			}
+		}
+	}
+
+	return string(out)
+}
+
+func isBase62(value string) bool {
+	for _, r := range value {
+		if !strings.ContainsRune(base62Alphabet, r) {
+			return false
+		}
+	}
+
+	return true
+}
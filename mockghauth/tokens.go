@@ -3,19 +3,69 @@ package mockghauth
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
 	"sync"
 	"time"
+)
 
-	"github.com/oklog/ulid/v2"
+var (
+	ErrTokenNotFound        = errors.New("token not found")
+	ErrTokenExpired         = errors.New("token expired")
+	ErrTokenClientMismatch  = errors.New("token issued to a different client")
+	ErrUnsupportedTokenKind = errors.New("unsupported token kind")
 )
 
+// Token is an issued access token and what it was issued for. Installation
+// tokens carry the installation and the permissions and repositories they may
+// access.
+type Token struct {
+	ID                  int64             `json:"id,omitempty"`
+	Kind                TokenKind         `json:"kind,omitempty"`
+	ClientID            string            `json:"client_id,omitempty"`
+	User                string            `json:"user,omitempty"`
+	Scopes              Scopes            `json:"scopes,omitempty"`
+	InstallationID      int64             `json:"installation_id,omitempty"`
+	Permissions         map[string]string `json:"permissions,omitempty"`
+	RepositorySelection string            `json:"repository_selection,omitempty"`
+	Repositories        []string          `json:"repositories,omitempty"`
+	CreatedAt           time.Time         `json:"created_at,omitzero"`
+	ExpiresAt           time.Time         `json:"expires_at,omitzero"`
+}
+
+// UnmarshalJSON accepts either a token object or, for older token files, a
+// bare expiry timestamp.
+func (t *Token) UnmarshalJSON(data []byte) error {
+	var ts time.Time
+	if err := json.Unmarshal(data, &ts); err == nil {
+		*t = Token{ExpiresAt: ts}
+		return nil
+	}
+
+	type plainToken Token
+
+	var v plainToken
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*t = Token(v)
+
+	return nil
+}
+
+// Expired reports whether the token has an expiry and it is before ts.
+func (t *Token) Expired(ts time.Time) bool {
+	return !t.ExpiresAt.IsZero() && t.ExpiresAt.Before(ts)
+}
+
 type Tokens struct {
 	lock   sync.RWMutex
 	expire time.Duration
-	tokens map[string]time.Time
+	lastID int64
+	tokens map[string]*Token
 }
 
 func (t *Tokens) checkMap() {
@@ -27,7 +77,7 @@ func (t *Tokens) checkMap() {
 		return
 	}
 
-	t.tokens = make(map[string]time.Time)
+	t.tokens = make(map[string]*Token)
 }
 
 func (t *Tokens) SetExpire(exp time.Duration) {
@@ -58,19 +108,89 @@ func (t *Tokens) WriteFile(filename string) error {
 }
 
 func (t *Tokens) New() string {
+	return t.Issue(&Token{})
+}
+
+// Issue stores a copy of tok with its creation and expiry times set and
+// returns the generated access token.
+func (t *Tokens) Issue(tok *Token) string {
+	return t.IssueWithExpire(tok, 0)
+}
+
+// IssueWithExpire is Issue with a lifetime of exp instead of the store
+// default. A zero exp uses the default for kinds that expire and leaves the
+// expiry unset, never expiring, for kinds that do not.
+func (t *Tokens) IssueWithExpire(tok *Token, exp time.Duration) string {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 
-	id := ulid.Make()
-
-	token := "ght_" + strings.ToLower(id.String())
+	token := GenerateToken(tok.Kind)
 
 	t.checkMap()
-	t.tokens[token] = time.Now().Add(t.expire)
+
+	if exp == 0 && tok.Kind.Expires() {
+		exp = t.expire
+	}
+
+	v := *tok
+	if v.ID == 0 {
+		t.lastID++
+		v.ID = t.lastID
+	}
+
+	v.CreatedAt = time.Now()
+	v.ExpiresAt = time.Time{}
+
+	if exp > 0 {
+		v.ExpiresAt = v.CreatedAt.Add(exp)
+	}
+
+	t.tokens[token] = &v
 
 	return token
 }
 
+// Reset replaces token with a new token for the same authorization, keeping
+// its ID and lifetime, and returns the new token.
+func (t *Tokens) Reset(token string) (string, bool) {
+	t.lock.Lock()
+	v, ok := t.tokens[token]
+	if ok {
+		delete(t.tokens, token)
+	}
+	t.lock.Unlock()
+
+	if !ok {
+		return "", false
+	}
+
+	var exp time.Duration
+	if !v.CreatedAt.IsZero() && v.ExpiresAt.After(v.CreatedAt) {
+		exp = v.ExpiresAt.Sub(v.CreatedAt)
+	}
+
+	return t.IssueWithExpire(v, exp), true
+}
+
+// DeleteGrant removes every token issued to clientID for user and returns how
+// many were removed.
+func (t *Tokens) DeleteGrant(clientID, user string) int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.checkMap()
+
+	count := 0
+	for k, v := range t.tokens {
+		if v.ClientID == clientID && strings.EqualFold(v.User, user) {
+			delete(t.tokens, k)
+			count++
+		}
+	}
+
+	return count
+}
+
 func (t *Tokens) Delete(token string) {
 	t.lock.Lock()
 	defer t.lock.Unlock()
@@ -85,12 +205,25 @@ func (t *Tokens) GetExpire(token string) (time.Time, bool) {
 
 	t.checkMap()
 	if v, ok := t.tokens[token]; ok {
-		return v, true
+		return v.ExpiresAt, true
 	}
 
 	return time.Time{}, false
 }
 
+func (t *Tokens) Get(token string) (*Token, bool) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	t.checkMap()
+	if v, ok := t.tokens[token]; ok {
+		out := *v
+		return &out, true
+	}
+
+	return nil, false
+}
+
 func (t *Tokens) Exists(token string) bool {
 	t.lock.RLock()
 	defer t.lock.RUnlock()
@@ -101,14 +234,42 @@ func (t *Tokens) Exists(token string) bool {
 	return ok
 }
 
+// Consume removes token on behalf of clientID and returns what it was issued
+// for, the way a refresh token is used exactly once.
+func (t *Tokens) Consume(token, clientID string) (*Token, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.checkMap()
+
+	v, ok := t.tokens[token]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+
+	if v.Expired(time.Now()) {
+		delete(t.tokens, token)
+		return nil, ErrTokenExpired
+	}
+
+	if v.ClientID != "" && v.ClientID != clientID {
+		return nil, ErrTokenClientMismatch
+	}
+
+	delete(t.tokens, token)
+
+	return v, nil
+}
+
+// Reaper removes every token that expired before ts.
 func (t *Tokens) Reaper(ts time.Time) {
 	t.lock.Lock()
 	defer t.lock.Unlock()
 
 	t.checkMap()
 
-	for k := range t.tokens {
-		if t.tokens[k].After(ts) {
+	for k, v := range t.tokens {
+		if v.Expired(ts) {
 			delete(t.tokens, k)
 		}
 	}
@@ -1,6 +1,7 @@
 package mockghauth_test
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -55,21 +56,114 @@ func TestTokens_ReadFile(t *testing.T) {
 }
 
 func TestTokens_Reaper(t *testing.T) {
-	reaperTime := time.Now().Add(-1 * time.Second)
 	tr := &mockghauth.Tokens{}
 	tr.SetExpire(time.Second)
 
-	expireToken := tr.New()
+	expireToken := tr.Issue(&mockghauth.Token{Kind: mockghauth.TokenKindUserToServer})
+
+	tr.SetExpire(time.Hour)
+
+	freshToken := tr.Issue(&mockghauth.Token{Kind: mockghauth.TokenKindUserToServer})
 
 	if !tr.Exists(expireToken) {
 		t.Errorf("Tokens.Reaper() key = %s, expected to exist", expireToken)
 		return
 	}
 
-	tr.Reaper(reaperTime)
+	tr.Reaper(time.Now().Add(-1 * time.Second))
+
+	if !tr.Exists(expireToken) {
+		t.Errorf("Tokens.Reaper() key = %s, expected to be kept before expiry", expireToken)
+		return
+	}
+
+	tr.Reaper(time.Now().Add(2 * time.Second))
 
 	if tr.Exists(expireToken) {
 		t.Errorf("Tokens.Reaper() key = %s, expected to have been reaped", expireToken)
 		return
 	}
+
+	if !tr.Exists(freshToken) {
+		t.Errorf("Tokens.Reaper() key = %s, expected fresh token to be kept", freshToken)
+	}
+}
+
+func TestTokens_IssueExpiry(t *testing.T) {
+	tr := &mockghauth.Tokens{}
+	tr.SetExpire(time.Hour)
+
+	tests := []struct {
+		kind       mockghauth.TokenKind
+		wantExpire bool
+	}{
+		{"", false},
+		{mockghauth.TokenKindOAuth, false},
+		{mockghauth.TokenKindPersonal, false},
+		{mockghauth.TokenKindFineGrained, false},
+		{mockghauth.TokenKindUserToServer, true},
+		{mockghauth.TokenKindInstallation, true},
+		{mockghauth.TokenKindRefresh, true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.kind), func(t *testing.T) {
+			token := tr.Issue(&mockghauth.Token{Kind: tt.kind})
+
+			ts, ok := tr.GetExpire(token)
+			if !ok {
+				t.Fatalf("Tokens.Issue() key = %s, expected to exist", token)
+			}
+
+			if ts.IsZero() == tt.wantExpire {
+				t.Errorf("Tokens.Issue() expires_at = %s, expected expiry = %t", ts, tt.wantExpire)
+			}
+
+			tr.Reaper(time.Now().Add(24 * time.Hour))
+
+			if tr.Exists(token) == tt.wantExpire {
+				t.Errorf("Tokens.Reaper() key = %s, expected to be kept = %t", token, !tt.wantExpire)
+			}
+		})
+	}
+}
+
+func TestTokens_LegacyToken(t *testing.T) {
+	tr := &mockghauth.Tokens{}
+
+	token, ok := tr.Reset(tr.New())
+	if !ok {
+		t.Fatalf("Tokens.Reset() ok = false, expected = true")
+	}
+
+	tr.Reaper(time.Now().Add(24 * time.Hour))
+
+	if !tr.Exists(token) {
+		t.Errorf("Tokens.Reaper() key = %s, expected legacy token to be kept", token)
+	}
+}
+
+func TestTokens_Consume(t *testing.T) {
+	tr := &mockghauth.Tokens{}
+	tr.SetExpire(time.Hour)
+
+	token := tr.Issue(&mockghauth.Token{Kind: mockghauth.TokenKindRefresh, ClientID: "test-client"})
+
+	tests := []struct {
+		name     string
+		token    string
+		clientID string
+		wantErr  error
+	}{
+		{"other client", token, "other-client", mockghauth.ErrTokenClientMismatch},
+		{"issued client", token, "test-client", nil},
+		{"already consumed", token, "test-client", mockghauth.ErrTokenNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tr.Consume(tt.token, tt.clientID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Tokens.Consume() error: expected = %v, received = %v", tt.wantErr, err)
+			}
+		})
+	}
 }
@@ -3,23 +3,104 @@ package mockghauth
 import (
 	"bytes"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/dosquad/mock-oauth-test-server/internal/staticsrc"
 )
 
+const apiDocumentationURL = "https://docs.github.com/enterprise-server@3.8/rest"
+
+// GitHubOAuth is a request to the token or device code endpoints.
 type GitHubOAuth struct {
-	ClientID     string `json:"client_id"`
-	ClientSecret string `json:"client_secret"`
-	Code         string `json:"code"`
+	ClientID     string `form:"client_id"     json:"client_id"`
+	ClientSecret string `form:"client_secret" json:"client_secret"`
+	Code         string `form:"code"          json:"code"`
+	RedirectURI  string `form:"redirect_uri"  json:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier" json:"code_verifier"`
+	GrantType    string `form:"grant_type"    json:"grant_type"`
+	RefreshToken string `form:"refresh_token" json:"refresh_token"`
+	DeviceCode   string `form:"device_code"   json:"device_code"`
+	Scope        string `form:"scope"         json:"scope"`
 }
 
 type GitHubOAuthResponse struct {
-	AccessToken string `json:"access_token"`
-	Scope       string `json:"scope"`
-	TokenType   string `json:"token_type"`
+	XMLName               xml.Name `json:"-"                                  xml:"OAuth"`
+	AccessToken           string   `json:"access_token"                       xml:"access_token"`
+	ExpiresIn             int      `json:"expires_in,omitempty"               xml:"expires_in,omitempty"`
+	RefreshToken          string   `json:"refresh_token,omitempty"            xml:"refresh_token,omitempty"`
+	RefreshTokenExpiresIn int      `json:"refresh_token_expires_in,omitempty" xml:"refresh_token_expires_in,omitempty"`
+	Scope                 string   `json:"scope"                              xml:"scope"`
+	TokenType             string   `json:"token_type"                         xml:"token_type"`
+}
+
+// Values returns the response in its application/x-www-form-urlencoded form.
+func (r *GitHubOAuthResponse) Values() url.Values {
+	out := url.Values{
+		"access_token": []string{r.AccessToken},
+		"scope":        []string{r.Scope},
+		"token_type":   []string{r.TokenType},
+	}
+
+	if r.ExpiresIn > 0 {
+		out.Set("expires_in", strconv.Itoa(r.ExpiresIn))
+	}
+
+	if r.RefreshToken != "" {
+		out.Set("refresh_token", r.RefreshToken)
+		out.Set("refresh_token_expires_in", strconv.Itoa(r.RefreshTokenExpiresIn))
+	}
+
+	return out
+}
+
+// GitHubOAuthError is the error body returned by the OAuth endpoints.
+type GitHubOAuthError struct {
+	XMLName          xml.Name `json:"-"                  xml:"OAuth"`
+	Error            string   `json:"error"              xml:"error"`
+	ErrorDescription string   `json:"error_description"  xml:"error_description"`
+	ErrorURI         string   `json:"error_uri"          xml:"error_uri"`
+	Interval         int      `json:"interval,omitempty" xml:"interval,omitempty"`
+}
+
+// Values returns the error in its application/x-www-form-urlencoded form.
+func (e *GitHubOAuthError) Values() url.Values {
+	out := url.Values{
+		"error":             []string{e.Error},
+		"error_description": []string{e.ErrorDescription},
+		"error_uri":         []string{e.ErrorURI},
+	}
+
+	if e.Interval > 0 {
+		out.Set("interval", strconv.Itoa(e.Interval))
+	}
+
+	return out
+}
+
+// GitHubDeviceCodeResponse is the response to a device code request.
+type GitHubDeviceCodeResponse struct {
+	XMLName         xml.Name `json:"-"                xml:"OAuth"`
+	DeviceCode      string   `json:"device_code"      xml:"device_code"`
+	UserCode        string   `json:"user_code"        xml:"user_code"`
+	VerificationURI string   `json:"verification_uri" xml:"verification_uri"`
+	ExpiresIn       int      `json:"expires_in"       xml:"expires_in"`
+	Interval        int      `json:"interval"         xml:"interval"`
+}
+
+// Values returns the response in its application/x-www-form-urlencoded form.
+func (r *GitHubDeviceCodeResponse) Values() url.Values {
+	return url.Values{
+		"device_code":      []string{r.DeviceCode},
+		"user_code":        []string{r.UserCode},
+		"verification_uri": []string{r.VerificationURI},
+		"expires_in":       []string{strconv.Itoa(r.ExpiresIn)},
+		"interval":         []string{strconv.Itoa(r.Interval)},
+	}
 }
 
 type GitHubAPIUser struct {
@@ -64,6 +145,55 @@ type GitHubAPIUser struct {
 	Plan                    GitHubAPIUserPlan `json:"plan"`
 }
 
+// GitHubAPIUserResponse is a user as the API returns it to a token. Its
+// fields shadow the user's email and private profile fields, which are null
+// or left out when the token's scopes do not allow them.
+type GitHubAPIUserResponse struct {
+	GitHubAPIUser
+
+	Email                   *string            `json:"email"`
+	PrivateGists            *int               `json:"private_gists,omitempty"`
+	TotalPrivateRepos       *int               `json:"total_private_repos,omitempty"`
+	OwnedPrivateRepos       *int               `json:"owned_private_repos,omitempty"`
+	DiskUsage               *int               `json:"disk_usage,omitempty"`
+	Collaborators           *int               `json:"collaborators,omitempty"`
+	TwoFactorAuthentication *bool              `json:"two_factor_authentication,omitempty"`
+	Plan                    *GitHubAPIUserPlan `json:"plan,omitempty"`
+}
+
+// GitHubAPIEmail is an email address of the authenticated user. Visibility is
+// public, private or null for addresses that cannot be made public.
+type GitHubAPIEmail struct {
+	Email      string  `json:"email"`
+	Primary    bool    `json:"primary"`
+	Verified   bool    `json:"verified"`
+	Visibility *string `json:"visibility"`
+}
+
+// ForScopes returns the user limited to what a token with scopes may see:
+// private profile fields need user or read:user and the email needs user or
+// user:email.
+func (u *GitHubAPIUser) ForScopes(scopes Scopes) *GitHubAPIUserResponse {
+	out := &GitHubAPIUserResponse{GitHubAPIUser: *u}
+	user := &out.GitHubAPIUser
+
+	if scopes.HasAny("user", "read:user") {
+		out.PrivateGists = &user.PrivateGists
+		out.TotalPrivateRepos = &user.TotalPrivateRepos
+		out.OwnedPrivateRepos = &user.OwnedPrivateRepos
+		out.DiskUsage = &user.DiskUsage
+		out.Collaborators = &user.Collaborators
+		out.TwoFactorAuthentication = &user.TwoFactorAuthentication
+		out.Plan = &user.Plan
+	}
+
+	if user.Email != "" && scopes.HasAny("user", "user:email") {
+		out.Email = &user.Email
+	}
+
+	return out
+}
+
 //nolint:forbidigo // panic error.
 func urlMustResolve(baseURL *url.URL, relativePath string) *url.URL {
 	relURL, err := url.Parse(relativePath)
@@ -86,7 +216,15 @@ func timeMustParseDef(value string) time.Time {
 	return ts
 }
 
+// DefaultGitHubAPIUser returns the built-in octocat user with its URLs pointing
+// at baseURL in the GitHub Enterprise Server layout.
 func DefaultGitHubAPIUser(baseURL *url.URL) (*GitHubAPIUser, error) {
+	return DefaultGitHubAPIUserForAPI(baseURL, urlMustResolve(baseURL, ghesAPIPrefix+"/"))
+}
+
+// DefaultGitHubAPIUserForAPI returns the built-in octocat user with its web
+// URLs pointing at baseURL and its API resource URLs at apiURL.
+func DefaultGitHubAPIUserForAPI(baseURL, apiURL *url.URL) (*GitHubAPIUser, error) {
 	var user GitHubAPIUser
 	buf, bufErr := staticsrc.Content.ReadFile("api_v3_user.json")
 	if bufErr != nil {
@@ -97,22 +235,32 @@ func DefaultGitHubAPIUser(baseURL *url.URL) (*GitHubAPIUser, error) {
 		return nil, err
 	}
 
-	user.AvatarURL = urlMustResolve(baseURL, "/images/error/octocat_happy.gif").String()
-	user.URL = urlMustResolve(baseURL, "/api/v3/users/octocat").String()
-	user.HTMLURL = urlMustResolve(baseURL, "/octocat").String()
-	user.FollowersURL = urlMustResolve(baseURL, "/api/v3/users/octocat/followers").String()
-	user.FollowingURL = urlMustResolve(baseURL, "/api/v3/users/octocat/following{/other_user}").String()
-	user.GistsURL = urlMustResolve(baseURL, "/api/v3/users/octocat/gists{/gist_id}").String()
-	user.StarredURL = urlMustResolve(baseURL, "/api/v3/users/octocat/starred{/owner}{/repo}").String()
-	user.SubscriptionsURL = urlMustResolve(baseURL, "/api/v3/users/octocat/subscriptions").String()
-	user.OrganizationsURL = urlMustResolve(baseURL, "/api/v3/users/octocat/orgs").String()
-	user.ReposURL = urlMustResolve(baseURL, "/api/v3/users/octocat/repos").String()
-	user.EventsURL = urlMustResolve(baseURL, "/api/v3/users/octocat/events{/privacy}").String()
-	user.ReceivedEventsURL = urlMustResolve(baseURL, "/api/v3/users/octocat/received_events").String()
 	user.CreatedAt = timeMustParseDef("2008-01-14T04:33:35Z")
 	user.UpdatedAt = timeMustParseDef("2008-01-14T04:33:35Z")
 
-	return &user, nil
+	return user.WithURLs(baseURL, apiURL), nil
+}
+
+// WithURLs returns a copy of the user with its web URLs pointing at baseURL
+// and its API resource URLs at apiURL.
+func (u *GitHubAPIUser) WithURLs(baseURL, apiURL *url.URL) *GitHubAPIUser {
+	out := *u
+	login := url.PathEscape(u.Login)
+
+	out.AvatarURL = urlMustResolve(baseURL, "/images/error/octocat_happy.gif").String()
+	out.URL = apiMustResolve(apiURL, "/users/"+login).String()
+	out.HTMLURL = urlMustResolve(baseURL, "/"+login).String()
+	out.FollowersURL = apiMustResolve(apiURL, "/users/"+login+"/followers").String()
+	out.FollowingURL = apiMustResolve(apiURL, "/users/"+login+"/following{/other_user}").String()
+	out.GistsURL = apiMustResolve(apiURL, "/users/"+login+"/gists{/gist_id}").String()
+	out.StarredURL = apiMustResolve(apiURL, "/users/"+login+"/starred{/owner}{/repo}").String()
+	out.SubscriptionsURL = apiMustResolve(apiURL, "/users/"+login+"/subscriptions").String()
+	out.OrganizationsURL = apiMustResolve(apiURL, "/users/"+login+"/orgs").String()
+	out.ReposURL = apiMustResolve(apiURL, "/users/"+login+"/repos").String()
+	out.EventsURL = apiMustResolve(apiURL, "/users/"+login+"/events{/privacy}").String()
+	out.ReceivedEventsURL = apiMustResolve(apiURL, "/users/"+login+"/received_events").String()
+
+	return &out
 }
 
 type GitHubAPIUserPlan struct {
@@ -130,7 +278,7 @@ type GitHubAPIError struct {
 func UnauthorizedGitHubAPIError(err ...any) *GitHubAPIError {
 	out := &GitHubAPIError{
 		Message:          "Must authenticate to access this API.",
-		DocumentationURL: "https://docs.github.com/enterprise-server@3.8/rest",
+		DocumentationURL: apiDocumentationURL,
 	}
 	if len(err) > 0 {
 		switch v := err[0].(type) {
@@ -145,3 +293,269 @@ func UnauthorizedGitHubAPIError(err ...any) *GitHubAPIError {
 
 	return out
 }
+
+// NotFoundGitHubAPIError is the body GitHub returns for unknown or hidden
+// resources.
+func NotFoundGitHubAPIError() *GitHubAPIError {
+	return &GitHubAPIError{
+		Message:          "Not Found",
+		DocumentationURL: apiDocumentationURL,
+	}
+}
+
+// ForbiddenGitHubAPIError is the body GitHub returns when a GitHub App token
+// lacks the permission needed for a resource.
+func ForbiddenGitHubAPIError() *GitHubAPIError {
+	return &GitHubAPIError{
+		Message:          "Resource not accessible by integration",
+		DocumentationURL: apiDocumentationURL,
+	}
+}
+
+// ScopesGitHubAPIError is the body GitHub returns when an OAuth or personal
+// access token holds none of the accepted scopes.
+func ScopesGitHubAPIError(accepted, granted Scopes) *GitHubAPIError {
+	quote := func(scopes Scopes) string {
+		if len(scopes) == 0 {
+			return "[]"
+		}
+
+		return "['" + strings.Join(scopes, "', '") + "']"
+	}
+
+	return &GitHubAPIError{
+		Message: "Your token has not been granted the required scopes to access this resource. It requires one of " +
+			"the following scopes: " + quote(accepted) + ", but your token has only been granted the: " +
+			quote(granted) + " scopes.",
+		DocumentationURL: apiDocumentationURL,
+	}
+}
+
+// ValidationGitHubAPIError is the body GitHub returns when a request body is
+// missing a required field.
+func ValidationGitHubAPIError(field string) *GitHubAPIError {
+	return &GitHubAPIError{
+		Message:          "Invalid request.\n\n\"" + field + "\" wasn't supplied.",
+		DocumentationURL: apiDocumentationURL,
+	}
+}
+
+// BadCredentialsGitHubAPIError is the body GitHub returns for an unknown,
+// revoked or expired token.
+func BadCredentialsGitHubAPIError() *GitHubAPIError {
+	return &GitHubAPIError{
+		Message:          "Bad credentials",
+		DocumentationURL: apiDocumentationURL,
+	}
+}
+
+// GitHubAPIOrganization is the organization object returned by the org
+// listing endpoints.
+type GitHubAPIOrganization struct {
+	Login            string `json:"login"`
+	ID               int    `json:"id"`
+	NodeID           string `json:"node_id"`
+	URL              string `json:"url"`
+	ReposURL         string `json:"repos_url"`
+	EventsURL        string `json:"events_url"`
+	HooksURL         string `json:"hooks_url"`
+	IssuesURL        string `json:"issues_url"`
+	MembersURL       string `json:"members_url"`
+	PublicMembersURL string `json:"public_members_url"`
+	AvatarURL        string `json:"avatar_url"`
+	Description      string `json:"description"`
+}
+
+// GitHubAPIOrgMembership is a user's membership of an organization.
+type GitHubAPIOrgMembership struct {
+	URL             string                 `json:"url"`
+	State           string                 `json:"state"`
+	Role            string                 `json:"role"`
+	OrganizationURL string                 `json:"organization_url"`
+	Organization    *GitHubAPIOrganization `json:"organization"`
+	User            *GitHubAPIUserResponse `json:"user"`
+}
+
+// GitHubAPITeam is a team object as listed by GET /user/teams.
+type GitHubAPITeam struct {
+	ID              int                    `json:"id"`
+	NodeID          string                 `json:"node_id"`
+	URL             string                 `json:"url"`
+	HTMLURL         string                 `json:"html_url"`
+	Name            string                 `json:"name"`
+	Slug            string                 `json:"slug"`
+	Description     string                 `json:"description"`
+	Privacy         string                 `json:"privacy"`
+	Permission      string                 `json:"permission"`
+	MembersURL      string                 `json:"members_url"`
+	RepositoriesURL string                 `json:"repositories_url"`
+	Parent          *GitHubAPITeam         `json:"parent"`
+	Organization    *GitHubAPIOrganization `json:"organization,omitempty"`
+}
+
+// GitHubAPITeamMembership is a user's membership of a team.
+type GitHubAPITeamMembership struct {
+	URL   string `json:"url"`
+	Role  string `json:"role"`
+	State string `json:"state"`
+}
+
+// GitHubAPIRepository is the repository object returned by the repository
+// endpoints. Permissions is only set for authenticated requests.
+type GitHubAPIRepository struct {
+	ID               int                       `json:"id"`
+	NodeID           string                    `json:"node_id"`
+	Name             string                    `json:"name"`
+	FullName         string                    `json:"full_name"`
+	Owner            *GitHubAPIUserResponse    `json:"owner"`
+	Private          bool                      `json:"private"`
+	HTMLURL          string                    `json:"html_url"`
+	Description      string                    `json:"description"`
+	Fork             bool                      `json:"fork"`
+	URL              string                    `json:"url"`
+	CloneURL         string                    `json:"clone_url"`
+	CollaboratorsURL string                    `json:"collaborators_url"`
+	Visibility       string                    `json:"visibility"`
+	DefaultBranch    string                    `json:"default_branch"`
+	CreatedAt        time.Time                 `json:"created_at"`
+	UpdatedAt        time.Time                 `json:"updated_at"`
+	PushedAt         time.Time                 `json:"pushed_at"`
+	Permissions      *GitHubAPIRepoPermissions `json:"permissions,omitempty"`
+}
+
+// GitHubAPIRepoPermissions are the authenticated user's permissions on a
+// repository.
+type GitHubAPIRepoPermissions struct {
+	Admin    bool `json:"admin"`
+	Maintain bool `json:"maintain"`
+	Push     bool `json:"push"`
+	Triage   bool `json:"triage"`
+	Pull     bool `json:"pull"`
+}
+
+// GitHubAPIRepoCollaboratorPermission is a user's permission on a repository.
+// Permission is the legacy admin, write, read or none level and RoleName the
+// user's role.
+type GitHubAPIRepoCollaboratorPermission struct {
+	Permission string                 `json:"permission"`
+	RoleName   string                 `json:"role_name"`
+	User       *GitHubAPIUserResponse `json:"user"`
+}
+
+// GitHubAuthorizationApp is the OAuth app an authorization was granted to.
+type GitHubAuthorizationApp struct {
+	ClientID string `json:"client_id"`
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+}
+
+// GitHubAuthorization is the authorization object returned by the OAuth
+// application token API.
+type GitHubAuthorization struct {
+	ID             int64                  `json:"id"`
+	URL            string                 `json:"url"`
+	Scopes         Scopes                 `json:"scopes"`
+	Token          string                 `json:"token"`
+	TokenLastEight string                 `json:"token_last_eight"`
+	HashedToken    string                 `json:"hashed_token"`
+	App            GitHubAuthorizationApp `json:"app"`
+	Note           *string                `json:"note"`
+	NoteURL        *string                `json:"note_url"`
+	UpdatedAt      time.Time              `json:"updated_at"`
+	CreatedAt      time.Time              `json:"created_at"`
+	Fingerprint    *string                `json:"fingerprint"`
+	ExpiresAt      *time.Time             `json:"expires_at"`
+	User           *GitHubAPIUserResponse `json:"user"`
+	Installation   any                    `json:"installation"`
+}
+
+// GitHubApp is the GitHub App object returned by GET /app.
+type GitHubApp struct {
+	ID                 int64                  `json:"id"`
+	Slug               string                 `json:"slug"`
+	NodeID             string                 `json:"node_id"`
+	ClientID           string                 `json:"client_id,omitempty"`
+	Owner              *GitHubAPIUserResponse `json:"owner"`
+	Name               string                 `json:"name"`
+	Description        string                 `json:"description"`
+	ExternalURL        string                 `json:"external_url"`
+	HTMLURL            string                 `json:"html_url"`
+	CreatedAt          time.Time              `json:"created_at"`
+	UpdatedAt          time.Time              `json:"updated_at"`
+	Permissions        map[string]string      `json:"permissions"`
+	Events             []string               `json:"events"`
+	InstallationsCount int                    `json:"installations_count"`
+}
+
+// GitHubInstallation is the installation object returned by the app
+// installation endpoints.
+type GitHubInstallation struct {
+	ID                  int64                  `json:"id"`
+	Account             *GitHubAPIUserResponse `json:"account"`
+	RepositorySelection string                 `json:"repository_selection"`
+	AccessTokensURL     string                 `json:"access_tokens_url"`
+	RepositoriesURL     string                 `json:"repositories_url"`
+	HTMLURL             string                 `json:"html_url"`
+	AppID               int64                  `json:"app_id"`
+	AppSlug             string                 `json:"app_slug"`
+	TargetID            int                    `json:"target_id"`
+	TargetType          string                 `json:"target_type"`
+	Permissions         map[string]string      `json:"permissions"`
+	Events              []string               `json:"events"`
+	CreatedAt           time.Time              `json:"created_at"`
+	UpdatedAt           time.Time              `json:"updated_at"`
+	SingleFileName      *string                `json:"single_file_name"`
+	SuspendedAt         *time.Time             `json:"suspended_at"`
+}
+
+// GitHubInstallationRepository is a repository an installation token can
+// access.
+type GitHubInstallationRepository struct {
+	Name     string `json:"name"`
+	FullName string `json:"full_name"`
+}
+
+// GitHubInstallationToken is the response to an installation access token
+// request.
+type GitHubInstallationToken struct {
+	Token               string                         `json:"token"`
+	ExpiresAt           time.Time                      `json:"expires_at"`
+	Permissions         map[string]string              `json:"permissions"`
+	RepositorySelection string                         `json:"repository_selection"`
+	Repositories        []GitHubInstallationRepository `json:"repositories,omitempty"`
+}
+
+// GitHubInstallationRepositories is the response listing the repositories an
+// installation access token can access.
+type GitHubInstallationRepositories struct {
+	TotalCount          int                    `json:"total_count"`
+	RepositorySelection string                 `json:"repository_selection"`
+	Repositories        []*GitHubAPIRepository `json:"repositories"`
+}
+
+// OIDCConfiguration is the OpenID Provider configuration document served at
+// /.well-known/openid-configuration.
+type OIDCConfiguration struct {
+	Issuer                           string   `json:"issuer"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	SubjectTypesSupported            []string `json:"subject_types_supported"`
+	ResponseTypesSupported           []string `json:"response_types_supported"`
+	ClaimsSupported                  []string `json:"claims_supported"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                  []string `json:"scopes_supported"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is an RSA JSON Web Key.
+type JWK struct {
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
@@ -0,0 +1,133 @@
+package mockghauth
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// contextPathUser returns the user in the path.
+func (s *Server) contextPathUser(c *gin.Context) (*User, bool) {
+	user, ok := s.users.Get(c.Param("username"))
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusNotFound, NotFoundGitHubAPIError())
+		return nil, false
+	}
+
+	return user, true
+}
+
+// apiUser returns user as a token with scopes may see them, which always
+// includes the email address they have made public. The follower counts come
+// from the follow graph for users in it, the fixture counts are kept for
+// everyone else.
+func (s *Server) apiUser(user *User, scopes Scopes) *GitHubAPIUserResponse {
+	out := user.WithURLs(s.baseURL, s.apiURL).ForScopes(scopes)
+
+	if public := user.PublicEmailAddresses(); out.Email == nil && len(public) > 0 {
+		out.Email = &public[0].Email
+	}
+
+	if followers := s.users.Followers(user.Login); len(followers) > 0 || user.Follows != nil {
+		out.Followers = len(followers)
+		out.Following = len(s.users.Followed(user.Login))
+	}
+
+	return out
+}
+
+// publicUser returns the public profile of user.
+func (s *Server) publicUser(user *User) *GitHubAPIUserResponse {
+	return s.apiUser(user, nil)
+}
+
+// publicUsers returns the public profiles of users.
+func (s *Server) publicUsers(users []*User) []*GitHubAPIUserResponse {
+	out := make([]*GitHubAPIUserResponse, 0, len(users))
+	for _, v := range users {
+		out = append(out, s.publicUser(v))
+	}
+
+	return out
+}
+
+func (s *Server) apiV3Users(c *gin.Context) {
+	user, ok := s.contextPathUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, s.publicUser(user))
+}
+
+// apiV3UsersOrgs lists the organizations the user has publicized their
+// membership of.
+func (s *Server) apiV3UsersOrgs(c *gin.Context) {
+	user, ok := s.contextPathUser(c)
+	if !ok {
+		return
+	}
+
+	orgs := s.orgs.PublicForUser(user.Login)
+
+	out := make([]*GitHubAPIOrganization, 0, len(orgs))
+	for _, v := range orgs {
+		out = append(out, s.githubOrganization(v))
+	}
+
+	c.JSON(http.StatusOK, out)
+}
+
+// apiV3UsersRepos lists the public repositories the user owns. Private
+// repositories are only listed by /user/repos, whatever the token's scopes.
+func (s *Server) apiV3UsersRepos(c *gin.Context) {
+	user, ok := s.contextPathUser(c)
+	if !ok {
+		return
+	}
+
+	requester := s.requesterLogin(c)
+
+	out := []*GitHubAPIRepository{}
+	for _, repo := range s.repos.OwnedBy(user.Login) {
+		if !repo.Private() {
+			out = append(out, s.githubRepository(repo, requester))
+		}
+	}
+
+	c.JSON(http.StatusOK, out)
+}
+
+func (s *Server) apiV3UsersFollowers(c *gin.Context) {
+	user, ok := s.contextPathUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, s.publicUsers(s.users.Followers(user.Login)))
+}
+
+func (s *Server) apiV3UsersFollowing(c *gin.Context) {
+	user, ok := s.contextPathUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, s.publicUsers(s.users.Followed(user.Login)))
+}
+
+// apiV3UsersFollowingUser checks whether the user follows target: 204 when
+// they do, 404 when they do not.
+func (s *Server) apiV3UsersFollowingUser(c *gin.Context) {
+	user, ok := s.contextPathUser(c)
+	if !ok {
+		return
+	}
+
+	if _, exists := s.users.Get(c.Param("target_user")); !exists || !user.IsFollowing(c.Param("target_user")) {
+		c.AbortWithStatusJSON(http.StatusNotFound, NotFoundGitHubAPIError())
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,189 @@
+package mockghauth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ErrUserNotFound is returned when a login does not match a fixture user.
+var ErrUserNotFound = errors.New("user not found")
+
+const emailVisibilityPublic = "public"
+
+// User is a fixture user that can log in to the mock server.
+type User struct {
+	GitHubAPIUser
+
+	// Emails are the user's email addresses. When unset the profile email,
+	// if any, is the user's only address: primary, verified and public.
+	Emails []*GitHubAPIEmail `json:"emails"`
+
+	// Follows are the logins of the users this user follows.
+	Follows []string `json:"follows,omitempty"`
+}
+
+// IsFollowing reports whether the user follows login.
+func (u *User) IsFollowing(login string) bool {
+	return slices.ContainsFunc(u.Follows, func(v string) bool {
+		return strings.EqualFold(v, login)
+	})
+}
+
+// EmailAddresses returns every email address of the user.
+func (u *User) EmailAddresses() []*GitHubAPIEmail {
+	if u.Emails != nil {
+		return u.Emails
+	}
+
+	if u.Email == "" {
+		return []*GitHubAPIEmail{}
+	}
+
+	visibility := emailVisibilityPublic
+
+	return []*GitHubAPIEmail{{Email: u.Email, Primary: true, Verified: true, Visibility: &visibility}}
+}
+
+// PublicEmailAddresses returns the email addresses the user has made public.
+func (u *User) PublicEmailAddresses() []*GitHubAPIEmail {
+	out := []*GitHubAPIEmail{}
+	for _, v := range u.EmailAddresses() {
+		if v.Visibility != nil && *v.Visibility == emailVisibilityPublic {
+			out = append(out, v)
+		}
+	}
+
+	return out
+}
+
+type Users struct {
+	lock  sync.RWMutex
+	users map[string]*User
+}
+
+func NewUsers() *Users {
+	return &Users{
+		users: make(map[string]*User),
+	}
+}
+
+// ReadFile loads users from a JSON object keyed by login. Entries without a
+// login take it from their key.
+func (u *Users) ReadFile(filename string) error {
+	if filename != "" {
+		buf, fileErr := os.ReadFile(filename)
+		if fileErr != nil {
+			return fmt.Errorf("unable to read users-file(%s): %w", filename, fileErr)
+		}
+
+		users := map[string]*User{}
+		if err := json.NewDecoder(bytes.NewReader(buf)).Decode(&users); err != nil {
+			return fmt.Errorf("unable to parse users-file(%s): %w", filename, err)
+		}
+
+		for login, user := range users {
+			if user.Login == "" {
+				user.Login = login
+			}
+
+			u.Add(user)
+		}
+	}
+
+	return nil
+}
+
+func (u *Users) WriteFile(filename string) error {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+
+	buf := bytes.NewBuffer(nil)
+	if err := json.NewEncoder(buf).Encode(u.users); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, buf.Bytes(), 0o600)
+}
+
+// Add adds or replaces a user, logins are case-insensitive.
+func (u *Users) Add(user *User) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	u.users[strings.ToLower(user.Login)] = user
+}
+
+func (u *Users) Get(login string) (*User, bool) {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+
+	v, ok := u.users[strings.ToLower(login)]
+	return v, ok
+}
+
+// Logins returns the login of every user, sorted.
+func (u *Users) Logins() []string {
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+
+	out := make([]string, 0, len(u.users))
+	for _, v := range u.users {
+		out = append(out, v.Login)
+	}
+
+	slices.Sort(out)
+
+	return out
+}
+
+// List returns every user, sorted by login.
+func (u *Users) List() []*User {
+	logins := u.Logins()
+
+	u.lock.RLock()
+	defer u.lock.RUnlock()
+
+	out := make([]*User, 0, len(logins))
+	for _, login := range logins {
+		if v, ok := u.users[strings.ToLower(login)]; ok {
+			out = append(out, v)
+		}
+	}
+
+	return out
+}
+
+// Followers returns the users following login, sorted by login.
+func (u *Users) Followers(login string) []*User {
+	out := []*User{}
+	for _, v := range u.List() {
+		if v.IsFollowing(login) {
+			out = append(out, v)
+		}
+	}
+
+	return out
+}
+
+// Followed returns the fixture users login follows, in the order they are
+// listed.
+func (u *Users) Followed(login string) []*User {
+	user, ok := u.Get(login)
+	if !ok {
+		return []*User{}
+	}
+
+	out := make([]*User, 0, len(user.Follows))
+	for _, v := range user.Follows {
+		if followed, exists := u.Get(v); exists {
+			out = append(out, followed)
+		}
+	}
+
+	return out
+}
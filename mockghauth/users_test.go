@@ -0,0 +1,172 @@
+package mockghauth_test
+
+import (
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/dosquad/mock-oauth-test-server/mockghauth"
+)
+
+func TestUsers_ReadFile(t *testing.T) {
+	tests := []struct {
+		name       string
+		filename   string
+		wantErr    bool
+		wantLogins []string
+	}{
+		{
+			name:       "Reading Test Data",
+			filename:   "../testdata/users.json",
+			wantLogins: []string{"hubot", "octocat", "outsider"},
+		},
+		{
+			name:     "Missing File",
+			filename: "../testdata/missing-users.json",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := mockghauth.NewUsers()
+
+			if err := u.ReadFile(tt.filename); (err != nil) != tt.wantErr {
+				t.Errorf("Users.ReadFile() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			if got := u.Logins(); !slices.Equal(got, tt.wantLogins) {
+				t.Errorf("Users.Logins() = %v, expected = %v", got, tt.wantLogins)
+			}
+
+			user, ok := u.Get("OctoCat")
+			if !ok {
+				t.Fatalf("Users.Get() login = OctoCat, expected to exist")
+			}
+
+			if !user.SiteAdmin || user.Plan.Name != "Medium" {
+				t.Errorf("Users.Get() login = OctoCat, received = %+v", user)
+			}
+
+			if got := len(user.EmailAddresses()); got != 4 {
+				t.Errorf("User.EmailAddresses() login = OctoCat, received = %d, expected = 4", got)
+			}
+
+			if got := user.PublicEmailAddresses(); len(got) != 1 || got[0].Email != "octocat@github.com" {
+				t.Errorf("User.PublicEmailAddresses() login = OctoCat, received = %v", got)
+			}
+		})
+	}
+}
+
+func TestUser_EmailAddresses(t *testing.T) {
+	email := "octocat@github.com"
+
+	tests := []struct {
+		name string
+		user *mockghauth.User
+		want int
+	}{
+		{"profile email", &mockghauth.User{GitHubAPIUser: mockghauth.GitHubAPIUser{Email: email}}, 1},
+		{"no email", &mockghauth.User{}, 0},
+		{"empty emails", &mockghauth.User{
+			GitHubAPIUser: mockghauth.GitHubAPIUser{Email: email},
+			Emails:        []*mockghauth.GitHubAPIEmail{},
+		}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.user.EmailAddresses(); len(got) != tt.want {
+				t.Errorf("User.EmailAddresses() = %v, expected %d addresses", got, tt.want)
+			}
+
+			if got := tt.user.PublicEmailAddresses(); len(got) != tt.want {
+				t.Errorf("User.PublicEmailAddresses() = %v, expected %d addresses", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsers_Followers(t *testing.T) {
+	u := mockghauth.NewUsers()
+	if err := u.ReadFile("../testdata/users.json"); err != nil {
+		t.Fatalf("Users.ReadFile() error = %v", err)
+	}
+
+	logins := func(users []*mockghauth.User) []string {
+		out := []string{}
+		for _, v := range users {
+			out = append(out, v.Login)
+		}
+
+		return out
+	}
+
+	tests := []struct {
+		login         string
+		wantFollowers []string
+		wantFollowed  []string
+	}{
+		{"octocat", []string{"hubot", "outsider"}, []string{"hubot"}},
+		{"HUBOT", []string{"octocat"}, []string{"octocat"}},
+		{"outsider", []string{}, []string{"octocat"}},
+		{"ghost", []string{"outsider"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.login, func(t *testing.T) {
+			if got := logins(u.Followers(tt.login)); !slices.Equal(got, tt.wantFollowers) {
+				t.Errorf("Users.Followers() = %v, expected = %v", got, tt.wantFollowers)
+			}
+
+			if got := logins(u.Followed(tt.login)); !slices.Equal(got, tt.wantFollowed) {
+				t.Errorf("Users.Followed() = %v, expected = %v", got, tt.wantFollowed)
+			}
+		})
+	}
+}
+
+func TestDefaultGitHubAPIUserForAPI(t *testing.T) {
+	baseURL, _ := url.Parse("http://localhost:8080")
+
+	tests := []struct {
+		name    string
+		apiURL  string
+		wantURL string
+	}{
+		{"ghes", "http://localhost:8080/api/v3/", "http://localhost:8080/api/v3/users/octocat"},
+		{"dotcom", "http://localhost:8080/", "http://localhost:8080/users/octocat"},
+		{"host", "http://api.localhost:8081/", "http://api.localhost:8081/users/octocat"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiURL, _ := url.Parse(tt.apiURL)
+
+			user, err := mockghauth.DefaultGitHubAPIUserForAPI(baseURL, apiURL)
+			if err != nil {
+				t.Fatalf("DefaultGitHubAPIUserForAPI() error = %v", err)
+			}
+
+			if user.URL != tt.wantURL || user.OrganizationsURL != tt.wantURL+"/orgs" {
+				t.Errorf("DefaultGitHubAPIUserForAPI() url = %q, organizations_url = %q, expected = %q",
+					user.URL, user.OrganizationsURL, tt.wantURL)
+			}
+
+			if user.HTMLURL != "http://localhost:8080/octocat" {
+				t.Errorf("DefaultGitHubAPIUserForAPI() html_url = %q, expected on the main host", user.HTMLURL)
+			}
+		})
+	}
+
+	user, err := mockghauth.DefaultGitHubAPIUser(baseURL)
+	if err != nil {
+		t.Fatalf("DefaultGitHubAPIUser() error = %v", err)
+	}
+
+	if user.URL != "http://localhost:8080/api/v3/users/octocat" {
+		t.Errorf("DefaultGitHubAPIUser() url = %q, expected the GitHub Enterprise Server layout", user.URL)
+	}
+}